@@ -2,6 +2,8 @@ package cfg
 
 import (
 	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -9,11 +11,92 @@ import (
 type Config struct {
 	Port        string
 	EnablePprof bool
+	StorePath   string
+	StoreTTL    time.Duration
+	ResultTTL   time.Duration
+	JobTTL      time.Duration
+
+	// WorkerCount is the number of repositories processed concurrently. APIConcurrency and CloneConcurrency limit how
+	// many of those workers may call the GitHub API, and clone and analyse repositories, at the same time.
+	WorkerCount      int
+	APIConcurrency   int
+	CloneConcurrency int
+
+	// Backend selects how the repository metadata is read, either one REST call per metric (BackendREST) or batched
+	// GraphQL queries of GraphQLBatchSize repositories (BackendGraphQL).
+	Backend          string
+	GraphQLBatchSize int
+
+	// Tokens are shared by every search, in addition to the tokens of the Authorization headers of a request.
+	// EnableAdmin exposes the rate limit state of the tokens.
+	Tokens      []string
+	EnableAdmin bool
+
+	// BaseURL and UploadURL point the GitHub client to a GitHub Enterprise Server instead of github.com.
+	BaseURL   string
+	UploadURL string
+
+	// AppID and AppPrivateKeyPath authenticate the server as a GitHub App, whose installations are added to the tokens.
+	// AppInstallationID limits the app to a single installation, 0 uses every installation of the app.
+	AppID             int64
+	AppPrivateKeyPath string
+	AppInstallationID int64
+
+	// HTTPCacheSize is the number of bytes of GitHub responses kept to make conditional requests, 0 disables the cache.
+	HTTPCacheSize int64
+
+	// NPMRegistry is the npm registry the dependencies of JavaScript and TypeScript repositories are downloaded from,
+	// PyPIIndex the simple index of the dependencies of Python repositories, CargoRegistry the download URL of Rust
+	// crates and MavenRepository the repository of the source jars of Java and Kotlin dependencies.
+	NPMRegistry     string
+	PyPIIndex       string
+	CargoRegistry   string
+	MavenRepository string
+
+	// GoProxy lists the module proxies the dependencies of Go repositories are downloaded from, like the GOPROXY of the
+	// go command. GoModCache is the directory the module zips are kept in, an empty path keeps nothing.
+	GoProxy    string
+	GoModCache string
 }
 
 const (
 	PortKey        = "PORT"
 	EnablePprofKey = "ENABLE_PPROF"
+	StorePathKey   = "STORE_PATH"
+	StoreTTLKey    = "STORE_TTL"
+	ResultTTLKey   = "RESULT_TTL"
+	JobTTLKey      = "JOB_TTL"
+
+	WorkerCountKey      = "WORKER_COUNT"
+	APIConcurrencyKey   = "API_CONCURRENCY"
+	CloneConcurrencyKey = "CLONE_CONCURRENCY"
+
+	BackendKey          = "GITHUB_BACKEND"
+	GraphQLBatchSizeKey = "GRAPHQL_BATCH_SIZE"
+
+	TokensKey      = "GITHUB_TOKENS"
+	EnableAdminKey = "ENABLE_ADMIN"
+
+	HTTPCacheSizeKey = "HTTP_CACHE_SIZE"
+
+	BaseURLKey   = "GITHUB_BASE_URL"
+	UploadURLKey = "GITHUB_UPLOAD_URL"
+
+	AppIDKey             = "GITHUB_APP_ID"
+	AppPrivateKeyPathKey = "GITHUB_APP_PRIVATE_KEY_PATH"
+	AppInstallationIDKey = "GITHUB_APP_INSTALLATION_ID"
+
+	NPMRegistryKey     = "NPM_REGISTRY"
+	PyPIIndexKey       = "PYPI_INDEX"
+	CargoRegistryKey   = "CARGO_REGISTRY"
+	MavenRepositoryKey = "MAVEN_REPOSITORY"
+	GoProxyKey         = "GOPROXY"
+	GoModCacheKey      = "GO_MOD_CACHE"
+)
+
+const (
+	BackendREST    = "rest"
+	BackendGraphQL = "graphql"
 )
 
 func NewConfig() *Config {
@@ -24,9 +107,70 @@ func NewConfig() *Config {
 	}
 
 	viper.AutomaticEnv()
+	viper.AllowEmptyEnv(true)
+
+	viper.SetDefault(StorePathKey, "data/store.db")
+	viper.SetDefault(StoreTTLKey, "168h")
+	viper.SetDefault(ResultTTLKey, "1h")
+	viper.SetDefault(JobTTLKey, "24h")
+	viper.SetDefault(WorkerCountKey, 4)
+	viper.SetDefault(APIConcurrencyKey, 4)
+	viper.SetDefault(CloneConcurrencyKey, 2)
+	viper.SetDefault(BackendKey, BackendREST)
+	viper.SetDefault(GraphQLBatchSizeKey, 25)
+	viper.SetDefault(HTTPCacheSizeKey, "64MB")
+	viper.SetDefault(NPMRegistryKey, "https://registry.npmjs.org")
+	viper.SetDefault(PyPIIndexKey, "https://pypi.org/simple")
+	viper.SetDefault(CargoRegistryKey, "https://static.crates.io/crates")
+	viper.SetDefault(MavenRepositoryKey, "https://repo.maven.apache.org/maven2")
+	viper.SetDefault(GoProxyKey, "https://proxy.golang.org")
+	viper.SetDefault(GoModCacheKey, "data/modcache")
 
 	return &Config{
 		Port:        viper.GetString(PortKey),
 		EnablePprof: viper.GetBool(EnablePprofKey),
+		StorePath:   viper.GetString(StorePathKey),
+		StoreTTL:    viper.GetDuration(StoreTTLKey),
+		ResultTTL:   viper.GetDuration(ResultTTLKey),
+		JobTTL:      viper.GetDuration(JobTTLKey),
+
+		WorkerCount:      viper.GetInt(WorkerCountKey),
+		APIConcurrency:   viper.GetInt(APIConcurrencyKey),
+		CloneConcurrency: viper.GetInt(CloneConcurrencyKey),
+
+		Backend:          strings.ToLower(viper.GetString(BackendKey)),
+		GraphQLBatchSize: viper.GetInt(GraphQLBatchSizeKey),
+
+		Tokens:      tokens(viper.GetString(TokensKey)),
+		EnableAdmin: viper.GetBool(EnableAdminKey),
+
+		BaseURL:   viper.GetString(BaseURLKey),
+		UploadURL: viper.GetString(UploadURLKey),
+
+		AppID:             viper.GetInt64(AppIDKey),
+		AppPrivateKeyPath: viper.GetString(AppPrivateKeyPathKey),
+		AppInstallationID: viper.GetInt64(AppInstallationIDKey),
+
+		HTTPCacheSize: int64(viper.GetSizeInBytes(HTTPCacheSizeKey)),
+
+		NPMRegistry:     viper.GetString(NPMRegistryKey),
+		PyPIIndex:       viper.GetString(PyPIIndexKey),
+		CargoRegistry:   viper.GetString(CargoRegistryKey),
+		MavenRepository: viper.GetString(MavenRepositoryKey),
+		GoProxy:         viper.GetString(GoProxyKey),
+		GoModCache:      viper.GetString(GoModCacheKey),
 	}
 }
+
+// tokens splits a comma separated list of tokens, ignoring the empty entries.
+func tokens(s string) []string {
+	var result []string
+
+	for _, token := range strings.Split(s, ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			result = append(result, token)
+		}
+	}
+
+	return result
+}
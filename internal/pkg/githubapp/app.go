@@ -0,0 +1,242 @@
+package githubapp
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+const (
+	// GitHub rejects JWTs that expire more than ten minutes in the future. The issue time is backdated to allow for
+	// clock drift between this server and GitHub.
+	jwtLifetime = 9 * time.Minute
+	jwtDrift    = time.Minute
+
+	// Installation tokens are valid for an hour, and are refreshed this long before they expire.
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// App authenticates as a GitHub App (https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app)
+// to mint installation access tokens.
+type App struct {
+	id      int64
+	key     *rsa.PrivateKey
+	baseURL string
+}
+
+// New creates an App from its ID and the path of its PEM encoded private key. An empty baseURL calls api.github.com,
+// otherwise the URL of a GitHub Enterprise Server.
+func New(id int64, keyPath string, baseURL string) (*App, error) {
+	b, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the GitHub App private key: %v", err)
+	}
+
+	key, err := parsePrivateKey(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &App{
+		id:      id,
+		key:     key,
+		baseURL: baseURL,
+	}, nil
+}
+
+// Installations is a method of the App struct. It returns the IDs of every installation of the app.
+func (a *App) Installations(ctx context.Context) ([]int64, error) {
+	client, err := a.client()
+	if err != nil {
+		return nil, err
+	}
+
+	opt := &github.ListOptions{PerPage: 100}
+
+	var ids []int64
+
+	for {
+		installations, resp, err := client.Apps.ListInstallations(ctx, opt)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list the GitHub App installations: %v", err)
+		}
+
+		for _, i := range installations {
+			ids = append(ids, i.GetID())
+		}
+
+		if resp.NextPage == 0 {
+			return ids, nil
+		}
+
+		opt.Page = resp.NextPage
+	}
+}
+
+// Installation is a method of the App struct. It returns the installation with the given ID. No token is minted before
+// the installation is first used.
+func (a *App) Installation(id int64) *Installation {
+	return &Installation{
+		app: a,
+		id:  id,
+	}
+}
+
+// client is a method of the App struct. It returns a GitHub client authenticated as the app itself, which may only call
+// the endpoints of the app, e.g. to mint installation tokens.
+func (a *App) client() (*github.Client, error) {
+	jwt, err := a.jwt(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	client := github.NewClient(nil).WithAuthToken(jwt)
+
+	if a.baseURL != "" {
+		if client, err = client.WithEnterpriseURLs(a.baseURL, a.baseURL); err != nil {
+			return nil, fmt.Errorf("invalid GitHub Enterprise URL: %v", err)
+		}
+	}
+
+	return client, nil
+}
+
+// jwt is a method of the App struct. It returns a JSON Web Token signed with the private key of the app with RS256.
+func (a *App) jwt(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-jwtDrift).Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+		"iss": strconv.FormatInt(a.id, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("unable to sign the GitHub App JWT: %v", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Installation is an installation of a GitHub App. Its access token is minted on first use and refreshed before it
+// expires.
+type Installation struct {
+	app *App
+	id  int64
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// ID is a method of the Installation struct. It returns the ID of the installation.
+func (i *Installation) ID() int64 {
+	return i.id
+}
+
+// Token is a method of the Installation struct. It returns a valid access token of the installation, minting a new one
+// if the current token is about to expire.
+func (i *Installation) Token(ctx context.Context) (string, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.token != "" && time.Until(i.expires) > tokenRefreshMargin {
+		return i.token, nil
+	}
+
+	client, err := i.app.client()
+	if err != nil {
+		return "", err
+	}
+
+	token, _, err := client.Apps.CreateInstallationToken(ctx, i.id, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to create an access token for the GitHub App installation %v: %v", i.id, err)
+	}
+
+	i.token, i.expires = token.GetToken(), token.GetExpiresAt().Time
+
+	return i.token, nil
+}
+
+// Transport is a method of the Installation struct. It returns an http.RoundTripper authenticating every request with
+// the access token of the installation before passing it on to base, which defaults to http.DefaultTransport when nil.
+func (i *Installation) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &transport{
+		installation: i,
+		base:         base,
+	}
+}
+
+type transport struct {
+	installation *Installation
+	base         http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.installation.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	// A RoundTripper must not modify the request it was given.
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return t.base.RoundTrip(req)
+}
+
+// parsePrivateKey parses an RSA private key in either the PKCS #1 format GitHub generates, or in the PKCS #8 format.
+func parsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("the GitHub App private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the GitHub App private key: %v", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the GitHub App private key is not an RSA key")
+	}
+
+	return rsaKey, nil
+}
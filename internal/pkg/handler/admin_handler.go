@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// TokensHandler returns the last known rate limits of the configured GitHub tokens. Tokens passed in the Authorization
+// header of a request are only pooled for that request, so they are not listed.
+func (h *Handler) TokensHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug(r.Method + " " + r.RequestURI)
+
+	if r.Method != http.MethodGet {
+		slog.Warn("invalid request method")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(h.Tokens.State())
+}
+
+// CacheHandler returns the statistics of the HTTP cache of GitHub responses, or purges it.
+func (h *Handler) CacheHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug(r.Method + " " + r.RequestURI)
+
+	if h.Cache == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(h.Cache.Stats())
+	case http.MethodDelete:
+		h.Cache.Purge()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		slog.Warn("invalid request method")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -1,15 +1,110 @@
 package handler
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
 	"github.com/haapjari/repository-search-api/internal/pkg/cfg"
+	"github.com/haapjari/repository-search-api/internal/pkg/githubapp"
+	"github.com/haapjari/repository-search-api/internal/pkg/httpcache"
+	"github.com/haapjari/repository-search-api/internal/pkg/service"
+	"github.com/haapjari/repository-search-api/internal/pkg/store"
+	"github.com/haapjari/repository-search-api/pkg/dependency"
 )
 
 type Handler struct {
 	Config *cfg.Config
+	Store  *store.Store
+	Jobs   *service.JobService
+	Tokens *service.TokenPool
+	Cache  *httpcache.Cache
+
+	// Results keeps the responses of paginated searches for the following pages.
+	Results *service.ResultSets
 }
 
-func NewHandler(config *cfg.Config) *Handler {
+func NewHandler(config *cfg.Config) (*Handler, error) {
+	var (
+		cache     *httpcache.Cache
+		transport http.RoundTripper
+	)
+
+	if config.HTTPCacheSize > 0 {
+		cache = httpcache.New(http.DefaultTransport, config.HTTPCacheSize)
+		transport = cache
+	}
+
+	tokens, err := service.NewTokenPool(transport, config.BaseURL, config.UploadURL, config.Tokens...)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.AppID != 0 {
+		if tokens, err = withApp(tokens, config); err != nil {
+			return nil, err
+		}
+	}
+
+	registerResolvers(config)
+
+	var st *store.Store
+
+	if config.StorePath != "" {
+		if st, err = store.Open(config.StorePath, config.StoreTTL); err != nil {
+			slog.Warn("unable to open the store, repositories will not be cached: " + err.Error())
+		}
+	}
+
 	return &Handler{
 		Config: config,
+		Store:  st,
+		Jobs:   service.NewJobService(config, st),
+		Tokens: tokens,
+		Cache:  cache,
+
+		Results: service.NewResultSets(config.ResultTTL),
+	}, nil
+}
+
+// registerResolvers registers the built-in dependency resolvers with the registries and repositories of the
+// configuration. Resolvers registered before, e.g. by a main package of another module, take precedence for the same
+// language.
+func registerResolvers(config *cfg.Config) {
+	npm := dependency.NewNPM(config.NPMRegistry, http.DefaultClient)
+	maven := dependency.NewMaven(config.MavenRepository, http.DefaultClient)
+
+	dependency.Register("Go", dependency.NewGo(config.GoProxy, config.GoModCache, http.DefaultClient))
+	dependency.Register("JavaScript", npm)
+	dependency.Register("TypeScript", npm)
+	dependency.Register("Python", dependency.NewPyPI(config.PyPIIndex, http.DefaultClient))
+	dependency.Register("Rust", dependency.NewCargo(config.CargoRegistry, http.DefaultClient))
+	dependency.Register("Java", maven)
+	dependency.Register("Kotlin", maven)
+}
+
+// withApp adds the installations of the configured GitHub App to the token pool. Without a configured installation,
+// every installation of the app is used.
+func withApp(tokens *service.TokenPool, config *cfg.Config) (*service.TokenPool, error) {
+	app, err := githubapp.New(config.AppID, config.AppPrivateKeyPath, config.BaseURL)
+	if err != nil {
+		return nil, err
 	}
+
+	installations := []int64{config.AppInstallationID}
+
+	if config.AppInstallationID == 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if installations, err = app.Installations(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	slog.Info(fmt.Sprintf("authenticating as GitHub App %v | Installations: %v", config.AppID, installations))
+
+	return tokens.WithApp(app, installations...), nil
 }
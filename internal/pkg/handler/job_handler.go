@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/haapjari/repository-search-api/internal/pkg/model"
+	"github.com/haapjari/repository-search-api/internal/pkg/service"
+)
+
+const (
+	ContentTypeEventStream string = "text/event-stream"
+
+	// eventHeartbeatInterval keeps idle event streams from being closed by proxies while a stage takes long.
+	eventHeartbeatInterval = 15 * time.Second
+)
+
+// JobsHandler submits a new search job. The search parameters are read either from a JSON body or, like the search
+// endpoint, from the query string.
+func (h *Handler) JobsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug(r.Method + " " + r.RequestURI)
+
+	if r.Method != http.MethodPost {
+		slog.Warn("invalid request method")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := queryParameters(r)
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		q = &model.QueryParameters{}
+		if err := json.NewDecoder(r.Body).Decode(q); err != nil {
+			slog.Warn("malformed job request body: " + err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !q.Validate() {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	tokens, ok := h.tokens(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	job, err := h.Jobs.Submit(tokens, q, credentials(r))
+	if err != nil {
+		slog.Error("unable to submit the job: " + err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+// JobHandler returns the status and progress of a job, or cancels it.
+func (h *Handler) JobHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug(r.Method + " " + r.RequestURI)
+
+	id, owner := r.PathValue("id"), credentials(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		job, err := h.Jobs.Get(id, owner)
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(job)
+	case http.MethodDelete:
+		running, err := h.Jobs.Cancel(id, owner)
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+
+		if !running {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		job, err := h.Jobs.Get(id, owner)
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(job)
+	default:
+		slog.Warn("invalid request method")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// JobResultsHandler returns the results of a completed job.
+func (h *Handler) JobResultsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug(r.Method + " " + r.RequestURI)
+
+	if r.Method != http.MethodGet {
+		slog.Warn("invalid request method")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	p, err := pageParameters(r)
+	if err != nil || (p != nil && p.id != "" && p.id != id) {
+		slog.Warn("invalid pagination parameters")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.Jobs.Result(id, credentials(r))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	// The results of a job are kept by the job itself, so its cursors point to the job.
+	if p != nil {
+		p.id = id
+		writePage(w, r, result, p)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// JobEventsHandler streams the progress of a job as Server-Sent Events until the job finishes or the client goes away.
+func (h *Handler) JobEventsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.Debug(r.Method + " " + r.RequestURI)
+
+	if r.Method != http.MethodGet {
+		slog.Warn("invalid request method")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, owner := r.PathValue("id"), credentials(r)
+
+	events, unsubscribe, err := h.Jobs.Events(id, owner)
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", ContentTypeEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, _ = fmt.Fprint(w, ": heartbeat\n\n")
+			_ = rc.Flush()
+		case e, ok := <-events:
+			if !ok {
+				// The search has finished, close the stream with the final state of the job.
+				if job, jobErr := h.Jobs.Get(id, owner); jobErr == nil {
+					writeEvent(w, "job", job)
+					_ = rc.Flush()
+				}
+				return
+			}
+
+			writeEvent(w, "progress", e)
+			_ = rc.Flush()
+		}
+	}
+}
+
+// writeEvent writes a single Server-Sent Event with a JSON encoded payload.
+func writeEvent(w http.ResponseWriter, event string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("unable to encode the event: " + err.Error())
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+func writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrJobNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, service.ErrJobForbidden):
+		slog.Warn("job requested with other credentials than it was submitted with")
+		w.WriteHeader(http.StatusForbidden)
+	case errors.Is(err, service.ErrJobNotFinished):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		slog.Error("unable to process the job request: " + err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
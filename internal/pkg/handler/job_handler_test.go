@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haapjari/repository-search-api/internal/pkg/cfg"
+	"github.com/haapjari/repository-search-api/internal/pkg/model"
+	"github.com/haapjari/repository-search-api/internal/pkg/service"
+)
+
+// testHandler returns a handler calling a fake GitHub, whose repository search finds three repositories.
+func testHandler(t *testing.T) *Handler {
+	t.Helper()
+
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/search/repositories") {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"total_count": 3, "items": [
+			{"name": "a", "full_name": "owner/a", "stargazers_count": 30},
+			{"name": "b", "full_name": "owner/b", "stargazers_count": 20},
+			{"name": "c", "full_name": "owner/c", "stargazers_count": 10}
+		]}`))
+	}))
+	t.Cleanup(github.Close)
+
+	conf := &cfg.Config{
+		BaseURL:          github.URL + "/",
+		WorkerCount:      1,
+		APIConcurrency:   1,
+		CloneConcurrency: 1,
+		GraphQLBatchSize: 1,
+		JobTTL:           time.Hour,
+		ResultTTL:        time.Hour,
+	}
+
+	tokens, err := service.NewTokenPool(nil, conf.BaseURL, conf.UploadURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Handler{
+		Config:  conf,
+		Jobs:    service.NewJobService(conf, nil),
+		Tokens:  tokens,
+		Results: service.NewResultSets(conf.ResultTTL),
+	}
+}
+
+// serve sends a request with the given Authorization header, if any, to handler and returns the response.
+func serve(handler http.HandlerFunc, method string, target string, pattern string, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, handler)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+
+	return w
+}
+
+const searchParameters = "firstCreationDate=2020-01-01&lastCreationDate=2020-01-31&language=Go&minStars=1&maxStars=100&order=desc&fields=stargazer_count"
+
+// submitJob submits a search job with the token and waits for it to complete.
+func submitJob(t *testing.T, h *Handler, token string) string {
+	t.Helper()
+
+	w := serve(h.JobsHandler, http.MethodPost, "/api/v1/jobs?"+searchParameters, "/api/v1/jobs", token)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("submitting the job returned %v", w.Code)
+	}
+
+	var job model.Job
+	if err := json.NewDecoder(w.Body).Decode(&job); err != nil {
+		t.Fatal(err)
+	}
+
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		w = serve(h.JobHandler, http.MethodGet, "/api/v1/jobs/"+job.ID, "/api/v1/jobs/{id}", token)
+		if err := json.NewDecoder(w.Body).Decode(&job); err != nil {
+			t.Fatal(err)
+		}
+
+		if job.Status != model.JobStatusRunning {
+			break
+		}
+	}
+
+	if job.Status != model.JobStatusCompleted {
+		t.Fatalf("job status = %v, error = %q, want %v", job.Status, job.Error, model.JobStatusCompleted)
+	}
+
+	return job.ID
+}
+
+func TestJobOwner(t *testing.T) {
+	h := testHandler(t)
+	id := submitJob(t, h, "owner")
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		pattern string
+	}{
+		{name: "status", handler: h.JobHandler, method: http.MethodGet, target: "/api/v1/jobs/" + id, pattern: "/api/v1/jobs/{id}"},
+		{name: "results", handler: h.JobResultsHandler, method: http.MethodGet, target: "/api/v1/jobs/" + id + "/results", pattern: "/api/v1/jobs/{id}/results"},
+		{name: "events", handler: h.JobEventsHandler, method: http.MethodGet, target: "/api/v1/jobs/" + id + "/events", pattern: "/api/v1/jobs/{id}/events"},
+		{name: "delete", handler: h.JobHandler, method: http.MethodDelete, target: "/api/v1/jobs/" + id, pattern: "/api/v1/jobs/{id}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, token := range []string{"other", ""} {
+				if w := serve(tt.handler, tt.method, tt.target, tt.pattern, token); w.Code != http.StatusForbidden {
+					t.Errorf("%v with token %q returned %v, want %v", tt.name, token, w.Code, http.StatusForbidden)
+				}
+			}
+
+			if w := serve(tt.handler, tt.method, tt.target, tt.pattern, "owner"); w.Code >= 300 {
+				t.Errorf("%v of the owner returned %v", tt.name, w.Code)
+			}
+		})
+	}
+}
+
+func TestResultCursorOwner(t *testing.T) {
+	h := testHandler(t)
+	id := submitJob(t, h, "owner")
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+		pattern string
+	}{
+		{
+			name:    "search",
+			handler: h.RepositoryHandler,
+			target:  "/api/v1/repos/search?perPage=1&" + searchParameters,
+			pattern: "/api/v1/repos/search",
+		},
+		{
+			name:    "job",
+			handler: h.JobResultsHandler,
+			target:  "/api/v1/jobs/" + id + "/results?perPage=1",
+			pattern: "/api/v1/jobs/{id}/results",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.handler, http.MethodGet, tt.target, tt.pattern, "owner")
+			if w.Code != http.StatusOK {
+				t.Fatalf("first page returned %v", w.Code)
+			}
+
+			var first model.RepositoryResponse
+			if err := json.NewDecoder(w.Body).Decode(&first); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(first.Items) != 1 || first.NextCursor == "" {
+				t.Fatalf("first page has %d items and cursor %q, want 1 item and a cursor", len(first.Items), first.NextCursor)
+			}
+
+			u, err := url.Parse(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			next := u.Path + "?" + url.Values{Cursor: {first.NextCursor}, PerPage: {"1"}}.Encode()
+
+			for _, token := range []string{"other", ""} {
+				if w = serve(tt.handler, http.MethodGet, next, tt.pattern, token); w.Code != http.StatusForbidden {
+					t.Errorf("next page with token %q returned %v, want %v", token, w.Code, http.StatusForbidden)
+				}
+			}
+
+			if w = serve(tt.handler, http.MethodGet, next, tt.pattern, "owner"); w.Code != http.StatusOK {
+				t.Fatalf("next page of the owner returned %v", w.Code)
+			}
+
+			var second model.RepositoryResponse
+			if err = json.NewDecoder(w.Body).Decode(&second); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(second.Items) != 1 || second.Items[0].FullName == first.Items[0].FullName {
+				t.Errorf("next page = %+v, want the second repository", second.Items)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/haapjari/repository-search-api/internal/pkg/model"
+)
+
+const (
+	PerPage string = "perPage"
+	Cursor  string = "cursor"
+
+	defaultPerPage = 100
+	maxPerPage     = 1000
+)
+
+var errInvalidPage = errors.New("invalid page parameters")
+
+// page is a window of a stored result set. The cursor of a page names the result set and the offset of its first item.
+type page struct {
+	id      string
+	offset  int
+	perPage int
+}
+
+// pageParameters reads the pagination parameters of the request. It returns nil if the client did not ask for a page.
+func pageParameters(r *http.Request) (*page, error) {
+	cursor, perPageParam := r.URL.Query().Get(Cursor), r.URL.Query().Get(PerPage)
+	if cursor == "" && perPageParam == "" {
+		return nil, nil
+	}
+
+	p := &page{perPage: defaultPerPage}
+
+	if perPageParam != "" {
+		perPage, err := strconv.Atoi(perPageParam)
+		if err != nil || perPage < 1 || perPage > maxPerPage {
+			return nil, errInvalidPage
+		}
+
+		p.perPage = perPage
+	}
+
+	if cursor != "" {
+		var err error
+		if p.id, p.offset, err = decodeCursor(cursor); err != nil {
+			return nil, errInvalidPage
+		}
+	}
+
+	return p, nil
+}
+
+func encodeCursor(id string, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id + ":" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (string, int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, err
+	}
+
+	id, offsetParam, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return "", 0, errInvalidPage
+	}
+
+	offset, err := strconv.Atoi(offsetParam)
+	if err != nil || offset < 0 {
+		return "", 0, errInvalidPage
+	}
+
+	return id, offset, nil
+}
+
+// writePage writes a page of a result set, with the cursor of the next page in the response and in a Link header
+// (https://www.rfc-editor.org/rfc/rfc8288).
+func writePage(w http.ResponseWriter, r *http.Request, response *model.RepositoryResponse, p *page) {
+	start := min(p.offset, len(response.Items))
+	end := min(start+p.perPage, len(response.Items))
+
+	result := *response
+	result.Items = response.Items[start:end]
+
+	links := []string{link(r, encodeCursor(p.id, 0), p.perPage, "first")}
+
+	if end < len(response.Items) {
+		result.NextCursor = encodeCursor(p.id, end)
+		links = append(links, link(r, result.NextCursor, p.perPage, "next"))
+	}
+
+	if start > 0 {
+		links = append(links, link(r, encodeCursor(p.id, max(start-p.perPage, 0)), p.perPage, "prev"))
+	}
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(&result)
+}
+
+// link returns a Link header value pointing to the page at cursor. The search parameters are left out, the cursor
+// alone identifies the result set.
+func link(r *http.Request, cursor string, perPage int, rel string) string {
+	u := url.URL{
+		Path: r.URL.Path,
+		RawQuery: url.Values{
+			Cursor:  {cursor},
+			PerPage: {strconv.Itoa(perPage)},
+		}.Encode(),
+	}
+
+	return fmt.Sprintf("<%s>; rel=%q", u.String(), rel)
+}
@@ -1,9 +1,15 @@
 package handler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"mime"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/haapjari/repository-search-api/internal/pkg/model"
@@ -17,27 +23,52 @@ const (
 	MinStars          string = "minStars"
 	MaxStars          string = "maxStars"
 	Order             string = "order"
+	Sort              string = "sort"
+	Fields            string = "fields"
+	Stream            string = "stream"
+	Refresh           string = "refresh"
+
+	Topic        string = "topic"
+	License      string = "license"
+	MinForks     string = "minForks"
+	MaxForks     string = "maxForks"
+	PushedAfter  string = "pushedAfter"
+	PushedBefore string = "pushedBefore"
+	Archived     string = "archived"
+	IsFork       string = "isFork"
+	Org          string = "org"
+	User         string = "user"
+	Size         string = "size"
+	Q            string = "q"
+)
+
+const (
+	ContentTypeNDJSON string = "application/x-ndjson"
 )
 
 func (h *Handler) RepositoryHandler(w http.ResponseWriter, r *http.Request) {
-	q := &model.QueryParameters{
-		FirstCreationDate: r.URL.Query().Get(FirstCreationDate),
-		LastCreationDate:  r.URL.Query().Get(LastCreationDate),
-		Language:          r.URL.Query().Get(Language),
-		MinStars:          r.URL.Query().Get(MinStars),
-		MaxStars:          r.URL.Query().Get(MaxStars),
-		Order:             r.URL.Query().Get(Order),
+	p, err := pageParameters(r)
+	if err != nil {
+		slog.Warn("invalid pagination parameters")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// The following pages are served from the result set of the first page, without repeating the search.
+	if p != nil && p.id != "" {
+		h.nextPage(w, r, p)
+		return
 	}
 
+	q := queryParameters(r)
+
 	if !q.Validate() {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	token := r.Header.Get("Authorization")
-
-	if token == "" || len(strings.TrimSpace(token)) < 2 {
-		slog.Warn("empty or malformed authorization header")
+	tokens, ok := h.tokens(r)
+	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
@@ -50,21 +81,207 @@ func (h *Handler) RepositoryHandler(w http.ResponseWriter, r *http.Request) {
 
 	slog.Debug(r.Method + " " + r.RequestURI)
 
-	svc := service.NewRepositoryService(token, q)
+	svc := service.NewRepositoryService(h.Config, h.Store, tokens, q)
+	defer svc.Stop()
 
-	repos, err := svc.Query()
+	if streaming(r) {
+		streamRepositories(w, r, svc)
+		return
+	}
+
+	repos, err := svc.Query(r.Context())
 	if err != nil {
 		slog.Error("unable to query the repositories: " + err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	svc.Stop()
+	response := &model.RepositoryResponse{
+		TotalCount:     svc.TotalCount(),
+		RetrievedCount: svc.Progress().Discovered,
+		Items:          repos,
+	}
+
+	if p != nil {
+		if p.id, err = h.Results.Put(response, credentials(r)); err != nil {
+			slog.Error("unable to keep the results: " + err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		writePage(w, r, response, p)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(&model.RepositoryResponse{
-		TotalCount: len(repos),
-		Items:      repos,
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// nextPage is a method of the Handler struct. It writes a page of a result set kept by an earlier request. Only a
+// request with the same Authorization headers as the one that created the result set may read it, as the results may
+// include private repositories of its tokens.
+func (h *Handler) nextPage(w http.ResponseWriter, r *http.Request, p *page) {
+	slog.Debug(r.Method + " " + r.RequestURI)
+
+	if r.Method != http.MethodGet {
+		slog.Warn("invalid request method")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	response, err := h.Results.Get(p.id, credentials(r))
+
+	switch {
+	case errors.Is(err, service.ErrResultSetForbidden):
+		slog.Warn("cursor requested with other credentials than the search")
+		w.WriteHeader(http.StatusForbidden)
+		return
+	case err != nil:
+		slog.Warn("unknown or expired cursor")
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+
+	writePage(w, r, response, p)
+}
+
+// streamRepositories writes every repository as its own JSON line as soon as it has been processed, followed by a
+// summary line with the counts and the errors of the search.
+func streamRepositories(w http.ResponseWriter, r *http.Request, svc *service.RepositoryService) {
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+
+	w.Header().Set("Content-Type", ContentTypeNDJSON)
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	err := svc.Stream(r.Context(), func(repo *model.Repository) {
+		_ = enc.Encode(repo)
+		_ = rc.Flush()
 	})
+
+	errs := svc.Errors()
+	if err != nil {
+		slog.Error("unable to query the repositories: " + err.Error())
+		errs = append(errs, err.Error())
+	}
+
+	_ = enc.Encode(&model.SearchSummary{
+		Summary: model.Summary{
+			TotalCount:     svc.TotalCount(),
+			RetrievedCount: svc.Progress().Discovered,
+			Progress:       svc.Progress(),
+			Errors:         errs,
+		},
+	})
+	_ = rc.Flush()
+}
+
+// streaming reports whether the client asked for the results as newline delimited JSON, either with the Accept header
+// or with the stream query parameter.
+func streaming(r *http.Request) bool {
+	if stream, err := strconv.ParseBool(r.URL.Query().Get(Stream)); err == nil && stream {
+		return true
+	}
+
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaType := range strings.Split(accept, ",") {
+			if mt, _, err := mime.ParseMediaType(strings.TrimSpace(mediaType)); err == nil && mt == ContentTypeNDJSON {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// queryParameters reads the search parameters from the query string of the request.
+func queryParameters(r *http.Request) *model.QueryParameters {
+	refresh, _ := strconv.ParseBool(r.URL.Query().Get(Refresh))
+
+	return &model.QueryParameters{
+		FirstCreationDate: r.URL.Query().Get(FirstCreationDate),
+		LastCreationDate:  r.URL.Query().Get(LastCreationDate),
+		Language:          r.URL.Query()[Language],
+		MinStars:          r.URL.Query().Get(MinStars),
+		MaxStars:          r.URL.Query().Get(MaxStars),
+		Order:             r.URL.Query().Get(Order),
+		Sort:              r.URL.Query().Get(Sort),
+		Fields:            fields(r),
+		Refresh:           refresh,
+
+		Topics:       r.URL.Query()[Topic],
+		License:      r.URL.Query().Get(License),
+		MinForks:     r.URL.Query().Get(MinForks),
+		MaxForks:     r.URL.Query().Get(MaxForks),
+		PushedAfter:  r.URL.Query().Get(PushedAfter),
+		PushedBefore: r.URL.Query().Get(PushedBefore),
+		Archived:     r.URL.Query().Get(Archived),
+		IsFork:       r.URL.Query().Get(IsFork),
+		Org:          r.URL.Query().Get(Org),
+		User:         r.URL.Query().Get(User),
+		Size:         r.URL.Query().Get(Size),
+		Q:            r.URL.Query().Get(Q),
+	}
+}
+
+// tokens is a method of the Handler struct. It returns the configured token pool extended with the tokens of the
+// request, and whether the resulting pool has any token to call GitHub with.
+func (h *Handler) tokens(r *http.Request) (*service.TokenPool, bool) {
+	tokens := h.Tokens.With(authorization(r)...)
+
+	if tokens.Len() == 0 {
+		slog.Warn("no GitHub tokens configured and no authorization header in the request")
+		return nil, false
+	}
+
+	return tokens, true
+}
+
+// credentials returns a hash of the tokens of the Authorization headers of the request, in any order, which identifies
+// the owner of a result set without keeping the tokens.
+func credentials(r *http.Request) string {
+	tokens := authorization(r)
+	slices.Sort(tokens)
+
+	sum := sha256.Sum256([]byte(strings.Join(tokens, "\n")))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// fields returns the fields selected by the request, given either as a comma separated list or as repeated parameters.
+func fields(r *http.Request) model.Fields {
+	var result model.Fields
+
+	for _, value := range r.URL.Query()[Fields] {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				result = append(result, field)
+			}
+		}
+	}
+
+	return result
+}
+
+// authorization returns the tokens of every Authorization header of the request, without the "Bearer" or "token"
+// scheme. Malformed headers are skipped.
+func authorization(r *http.Request) []string {
+	var tokens []string
+
+	for _, header := range r.Header.Values("Authorization") {
+		fields := strings.Fields(header)
+
+		switch {
+		case len(fields) == 1:
+			tokens = append(tokens, fields[0])
+		case len(fields) == 2 && (strings.EqualFold(fields[0], "Bearer") || strings.EqualFold(fields[0], "token")):
+			tokens = append(tokens, fields[1])
+		default:
+			slog.Warn("malformed authorization header")
+		}
+	}
+
+	return tokens
 }
@@ -0,0 +1,205 @@
+package httpcache
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/haapjari/repository-search-api/internal/pkg/model"
+)
+
+// Cache is an http.RoundTripper that makes GET requests conditional. Responses carrying an ETag or a Last-Modified
+// header are kept in memory, and their validators are sent with the next request for the same resource. A 304 Not
+// Modified, which GitHub does not count against the rate limit, is then answered from the cache. The least recently
+// used responses are evicted once the cache grows beyond its size limit.
+type Cache struct {
+	transport http.RoundTripper
+	maxBytes  int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int64
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type entry struct {
+	key          string
+	etag         string
+	lastModified string
+	header       http.Header
+	body         []byte
+}
+
+// New creates a cache of at most maxBytes of response bodies in front of transport, which defaults to
+// http.DefaultTransport when nil.
+func New(transport http.RoundTripper, maxBytes int64) *Cache {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &Cache{
+		transport: transport,
+		maxBytes:  maxBytes,
+		entries:   make(map[string]*list.Element),
+		lru:       list.New(),
+	}
+}
+
+// RoundTrip is a method of the Cache struct. It implements http.RoundTripper.
+func (c *Cache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return c.transport.RoundTrip(req)
+	}
+
+	k := key(req)
+	cached := c.get(k)
+
+	if cached != nil {
+		// A RoundTripper must not modify the request it was given.
+		req = req.Clone(req.Context())
+
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	resp, err := c.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		c.hits.Add(1)
+		_ = resp.Body.Close()
+
+		// The 304 carries the current rate limit, which has to replace the one stored with the cached response.
+		header := cached.header.Clone()
+		for name, values := range resp.Header {
+			header[name] = values
+		}
+
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(cached.body)),
+			ContentLength: int64(len(cached.body)),
+			Request:       resp.Request,
+		}, nil
+	}
+
+	c.misses.Add(1)
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (etag == "" && lastModified == "") {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	c.put(&entry{
+		key:          k,
+		etag:         etag,
+		lastModified: lastModified,
+		header:       resp.Header.Clone(),
+		body:         body,
+	})
+
+	return resp, nil
+}
+
+// Purge is a method of the Cache struct. It removes every cached response.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.size = 0
+}
+
+// Stats is a method of the Cache struct. It returns the size of the cache and how many requests it has answered.
+func (c *Cache) Stats() model.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return model.CacheStats{
+		Entries:  c.lru.Len(),
+		Bytes:    c.size,
+		MaxBytes: c.maxBytes,
+		Hits:     c.hits.Load(),
+		Misses:   c.misses.Load(),
+	}
+}
+
+func (c *Cache) get(k string) *entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[k]
+	if !ok {
+		return nil
+	}
+
+	c.lru.MoveToFront(el)
+
+	return el.Value.(*entry)
+}
+
+func (c *Cache) put(e *entry) {
+	size := int64(len(e.body))
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[e.key]; ok {
+		c.remove(el)
+	}
+
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.size += size
+
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove is a method of the Cache struct. It evicts a single entry. The caller must hold the lock.
+func (c *Cache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+
+	delete(c.entries, e.key)
+	c.size -= int64(len(e.body))
+}
+
+// key identifies a cached response by the requested URL, and by the headers GitHub varies its responses on. The
+// Authorization header is hashed, so tokens are not kept in memory longer than the request needs them.
+func key(req *http.Request) string {
+	auth := sha256.Sum256([]byte(req.Header.Get("Authorization")))
+
+	return req.URL.String() + "\x00" + req.Header.Get("Accept") + "\x00" + hex.EncodeToString(auth[:])
+}
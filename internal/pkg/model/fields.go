@@ -0,0 +1,139 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// The fields of a Repository, by their JSON name, that take a stage of their own to compute. The other fields are part
+// of the search results.
+const (
+	FieldOpenIssues             = "open_issues"
+	FieldClosedIssues           = "closed_issues"
+	FieldOpenPullRequestCount   = "open_pull_request_count"
+	FieldClosedPullRequestCount = "closed_pull_request_count"
+	FieldMergedPullRequestCount = "merged_pull_request_count"
+	FieldCommitCount            = "commit_count"
+	FieldCommitCountMethod      = "commit_count_method"
+	FieldLatestRelease          = "latest_release"
+	FieldTotalReleasesCount     = "total_releases_count"
+	FieldContributorCount       = "contributor_count"
+	FieldThirdPartyLOC          = "third_party_loc"
+	FieldSelfWrittenLOC         = "self_written_loc"
+	FieldUnresolvedDependencies = "unresolved_dependencies"
+
+	// FieldFullName identifies a repository, so it is always selected.
+	FieldFullName = "full_name"
+)
+
+// repositoryFields are the JSON names of the fields of a Repository, in the order they are encoded.
+var repositoryFields = func() []string {
+	t := reflect.TypeOf(Repository{})
+
+	var names []string
+
+	for i := range t.NumField() {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}()
+
+// Fields are the fields of a Repository a search computes and returns. No fields select every field.
+type Fields []string
+
+// Has is a method of the Fields type. It reports whether any of the given fields is selected.
+func (f Fields) Has(names ...string) bool {
+	if len(f) == 0 {
+		return true
+	}
+
+	for _, name := range names {
+		if slices.Contains(f, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Without is a method of the Fields type. It returns the selected fields except the given ones. The full name is always
+// kept, so the result never turns into a selection of every field by being empty.
+func (f Fields) Without(names ...string) Fields {
+	selected := f
+	if len(selected) == 0 {
+		selected = repositoryFields
+	}
+
+	result := Fields{FieldFullName}
+
+	for _, name := range selected {
+		if name != FieldFullName && !slices.Contains(names, name) {
+			result = append(result, name)
+		}
+	}
+
+	return result
+}
+
+// Valid is a method of the Fields type. It reports whether every field is a field of a Repository.
+func (f Fields) Valid() bool {
+	for _, name := range f {
+		if !slices.Contains(repositoryFields, name) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Select is a method of the Repository struct. It returns a copy of the repository that only encodes the given fields
+// to JSON, and the full name.
+func (r *Repository) Select(fields Fields) *Repository {
+	selected := *r
+	selected.fields = fields
+
+	return &selected
+}
+
+func (r *Repository) MarshalJSON() ([]byte, error) {
+	type repository Repository
+
+	b, err := json.Marshal((*repository)(r))
+	if err != nil || len(r.fields) == 0 {
+		return b, err
+	}
+
+	var values map[string]json.RawMessage
+	if err = json.Unmarshal(b, &values); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for _, name := range repositoryFields {
+		if name != FieldFullName && !r.fields.Has(name) {
+			continue
+		}
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(values[name])
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
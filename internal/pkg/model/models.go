@@ -3,6 +3,7 @@ package model
 import (
 	"encoding/json"
 	"log/slog"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -10,11 +11,35 @@ import (
 	"github.com/haapjari/repository-search-api/internal/pkg/util"
 )
 
+// RepositoryResponse is the result of a search. TotalCount is GitHub's total_count of the search, RetrievedCount the
+// number of repositories the search actually returned. NextCursor is set on a page of the response that is followed by
+// another page.
 type RepositoryResponse struct {
-	TotalCount int           `json:"total_count"`
-	Items      []*Repository `json:"items"`
+	TotalCount     int           `json:"total_count"`
+	RetrievedCount int           `json:"retrieved_count"`
+	Items          []*Repository `json:"items"`
+	NextCursor     string        `json:"next_cursor,omitempty"`
 }
 
+// SearchSummary is written as the last line of a streamed search, after every repository.
+type SearchSummary struct {
+	Summary Summary `json:"summary"`
+}
+
+type Summary struct {
+	TotalCount     int `json:"total_count"`
+	RetrievedCount int `json:"retrieved_count"`
+	Progress
+	Errors []string `json:"errors"`
+}
+
+const (
+	// CommitCountMethodAPI means the commits were counted with the GitHub API.
+	CommitCountMethodAPI = "api"
+	// CommitCountMethodClone means the commits were counted from a local clone of the repository.
+	CommitCountMethodClone = "clone"
+)
+
 type Repository struct {
 	Name                   string `json:"name"`
 	FullName               string `json:"full_name"`
@@ -25,25 +50,145 @@ type Repository struct {
 	ClosedIssues           int    `json:"closed_issues"`
 	OpenPullRequestCount   int    `json:"open_pull_request_count"`
 	ClosedPullRequestCount int    `json:"closed_pull_request_count"`
+	MergedPullRequestCount int    `json:"merged_pull_request_count"`
 	Forks                  int    `json:"forks"`
 	WatcherCount           int    `json:"watcher_count"`
 	SubscriberCount        int    `json:"subscriber_count"`
 	CommitCount            int    `json:"commit_count"`
+	CommitCountMethod      string `json:"commit_count_method"`
 	NetworkCount           int    `json:"network_count"`
 	LatestRelease          string `json:"latest_release"`
 	TotalReleasesCount     int    `json:"total_releases_count"`
 	ContributorCount       int    `json:"contributor_count"`
 	ThirdPartyLOC          int    `json:"third_party_loc"`
 	SelfWrittenLOC         int    `json:"self_written_loc"`
+
+	// UnresolvedDependencies are the dependencies whose code is missing from ThirdPartyLOC, because they could not be
+	// resolved to a version or downloaded.
+	UnresolvedDependencies []string `json:"unresolved_dependencies"`
+
+	// fields limits the fields encoded to JSON, see Select.
+	fields Fields
 }
 
+// QueryParameters describe a search. The parameters after Order are optional search qualifiers, see
+// https://docs.github.com/en/search-github/searching-on-github/searching-for-repositories.
 type QueryParameters struct {
-	FirstCreationDate string
-	LastCreationDate  string
-	Language          string
-	MinStars          string
-	MaxStars          string
-	Order             string
+	FirstCreationDate string    `json:"firstCreationDate"`
+	LastCreationDate  string    `json:"lastCreationDate"`
+	Language          Languages `json:"language"`
+	MinStars          string    `json:"minStars"`
+	MaxStars          string    `json:"maxStars"`
+	Order             string    `json:"order"`
+	Sort              string    `json:"sort,omitempty"`
+	Fields            Fields    `json:"fields,omitempty"`
+	Refresh           bool      `json:"refresh"`
+
+	Topics       []string `json:"topic,omitempty"`
+	License      string   `json:"license,omitempty"`
+	MinForks     string   `json:"minForks,omitempty"`
+	MaxForks     string   `json:"maxForks,omitempty"`
+	PushedAfter  string   `json:"pushedAfter,omitempty"`
+	PushedBefore string   `json:"pushedBefore,omitempty"`
+	Archived     string   `json:"archived,omitempty"`
+	IsFork       string   `json:"isFork,omitempty"`
+	Org          string   `json:"org,omitempty"`
+	User         string   `json:"user,omitempty"`
+	Size         string   `json:"size,omitempty"`
+	Q            string   `json:"q,omitempty"`
+}
+
+// ForkOnly is the value of IsFork searching forks only. True includes forks besides the other repositories, which
+// GitHub leaves out of the results otherwise.
+const ForkOnly = "only"
+
+// The fields search results can be sorted by. Without a sort, results are sorted by stars.
+const (
+	SortStars            = "stars"
+	SortForks            = "forks"
+	SortHelpWantedIssues = "help-wanted-issues"
+	SortUpdated          = "updated"
+	SortBestMatch        = "best-match"
+)
+
+// Languages are the languages of a search. In JSON, a single language may also be given as a plain string.
+type Languages []string
+
+func (l *Languages) UnmarshalJSON(b []byte) error {
+	var language string
+	if err := json.Unmarshal(b, &language); err == nil {
+		*l = Languages{language}
+		return nil
+	}
+
+	return json.Unmarshal(b, (*[]string)(l))
+}
+
+var (
+	// topicPattern and loginPattern follow the rules GitHub applies to topics and to user and organization names.
+	topicPattern   = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,49}$`)
+	loginPattern   = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,38})$`)
+	licensePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+-]*$`)
+	sizePattern    = regexp.MustCompile(`^(?:[<>]=?)?[0-9]+$|^[0-9]+\.\.[0-9]+$`)
+)
+
+// searchTextLimit is the longest free-text part of a search GitHub accepts.
+const searchTextLimit = 256
+
+type JobStatus string
+
+const (
+	JobStatusRunning   JobStatus = "running"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusCancelled JobStatus = "cancelled"
+)
+
+type Job struct {
+	ID         string           `json:"id"`
+	Status     JobStatus        `json:"status"`
+	Query      *QueryParameters `json:"query"`
+	Progress   Progress         `json:"progress"`
+	Error      string           `json:"error,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+type Stage string
+
+const (
+	StageSearch        Stage = "search"
+	StageCached        Stage = "cached"
+	StageMetadata      Stage = "metadata"
+	StagePulls         Stage = "pulls"
+	StageIssues        Stage = "issues"
+	StageCommits       Stage = "commits"
+	StageReleases      Stage = "releases"
+	StageLatestRelease Stage = "latest_release"
+	StageContributors  Stage = "contributors"
+	StageClone         Stage = "clone"
+	StageLOC           Stage = "loc"
+	StageThirdPartyLOC Stage = "third_party_loc"
+	StageCompleted     Stage = "completed"
+	StageDone          Stage = "done"
+)
+
+// ProgressEvent describes a step of a running search. Elapsed is measured in seconds from the start of the search,
+// RepositoryElapsed from the start of the repository. RateLimitRemaining is -1 until the first GitHub response.
+type ProgressEvent struct {
+	Repository         string    `json:"repository,omitempty"`
+	Stage              Stage     `json:"stage"`
+	Elapsed            float64   `json:"elapsed"`
+	RepositoryElapsed  float64   `json:"repository_elapsed,omitempty"`
+	RateLimitRemaining int       `json:"rate_limit_remaining"`
+	Progress           Progress  `json:"progress"`
+	Time               time.Time `json:"time"`
+}
+
+type Progress struct {
+	Discovered int `json:"discovered"`
+	Processed  int `json:"processed"`
+	Failed     int `json:"failed"`
 }
 
 func (q *QueryParameters) ToString() string {
@@ -72,17 +217,36 @@ func (q *QueryParameters) Validate() bool {
 		return false
 	}
 
-	if q.Language == "" || !util.OnlyLetters(q.Language) {
-		slog.Warn("empty or malformed language parameter")
+	if len(q.Language) == 0 {
+		slog.Warn("empty language parameter")
 		return false
 	}
 
+	// Lines of code are only counted for the languages gocloc knows, a search for any other language can only select
+	// the other fields. No fields select every field, including the lines of code.
+	loc := q.Fields.Has(FieldSelfWrittenLOC) || q.Fields.Has(FieldThirdPartyLOC)
+
+	for _, language := range q.Language {
+		name, ok := util.GitHubLanguage(language)
+		if !ok {
+			slog.Warn("unknown language parameter: " + language)
+			return false
+		}
+
+		// Aliases such as "golang" are resolved to the name GitHub reports for the repositories, which CalcLOC counts.
+		if _, ok = util.KnownLanguage(name); loc && !ok {
+			slog.Warn("unable to count the lines of code of the language parameter: " + language)
+			return false
+		}
+	}
+
 	if q.MinStars == "" {
 		slog.Warn("empty min stars parameter")
 		return false
 	}
 
-	if _, err := strconv.Atoi(q.MinStars); err != nil {
+	minStars, err := strconv.Atoi(q.MinStars)
+	if err != nil || minStars < 0 {
 		slog.Warn("invalid min stars parameter")
 		return false
 	}
@@ -92,16 +256,114 @@ func (q *QueryParameters) Validate() bool {
 		return false
 	}
 
-	if _, err := strconv.Atoi(q.MaxStars); err != nil {
+	maxStars, err := strconv.Atoi(q.MaxStars)
+	if err != nil || maxStars < 0 {
 		slog.Warn("invalid max stars parameter")
 		return false
 	}
 
+	if minStars > maxStars {
+		slog.Warn("min stars parameter is above the max stars parameter")
+		return false
+	}
+
 	if q.Order == "" || !(strings.EqualFold(q.Order, "asc") ||
 		strings.EqualFold(q.Order, "desc")) {
 		slog.Warn("invalid or missing order parameter")
 		return false
 	}
 
+	if !q.Fields.Valid() {
+		slog.Warn("invalid fields parameter")
+		return false
+	}
+
+	switch q.Sort {
+	case "", SortStars, SortForks, SortHelpWantedIssues, SortUpdated, SortBestMatch:
+	default:
+		slog.Warn("invalid sort parameter")
+		return false
+	}
+
+	return q.validateQualifiers()
+}
+
+// validateQualifiers checks the optional search qualifiers, so that none of them can inject qualifiers of its own into
+// the search query.
+func (q *QueryParameters) validateQualifiers() bool {
+	for _, topic := range q.Topics {
+		if !topicPattern.MatchString(topic) {
+			slog.Warn("invalid topic parameter")
+			return false
+		}
+	}
+
+	if q.License != "" && !licensePattern.MatchString(q.License) {
+		slog.Warn("invalid license parameter")
+		return false
+	}
+
+	for _, forks := range []string{q.MinForks, q.MaxForks} {
+		if _, err := strconv.Atoi(forks); forks != "" && err != nil {
+			slog.Warn("invalid forks parameter")
+			return false
+		}
+	}
+
+	for _, pushed := range []string{q.PushedAfter, q.PushedBefore} {
+		if _, err := time.Parse("2006-01-02", pushed); pushed != "" && err != nil {
+			slog.Warn("invalid or malformed pushed date")
+			return false
+		}
+	}
+
+	if _, err := strconv.ParseBool(q.Archived); q.Archived != "" && err != nil {
+		slog.Warn("invalid archived parameter")
+		return false
+	}
+
+	if _, err := strconv.ParseBool(q.IsFork); q.IsFork != "" && q.IsFork != ForkOnly && err != nil {
+		slog.Warn("invalid fork parameter")
+		return false
+	}
+
+	for _, login := range []string{q.Org, q.User} {
+		if login != "" && !loginPattern.MatchString(login) {
+			slog.Warn("invalid org or user parameter")
+			return false
+		}
+	}
+
+	if q.Size != "" && !sizePattern.MatchString(q.Size) {
+		slog.Warn("invalid size parameter")
+		return false
+	}
+
+	if len(q.Q) > searchTextLimit || strings.ContainsAny(q.Q, ":\"") {
+		slog.Warn("free-text search terms are too long or contain qualifiers")
+		return false
+	}
+
 	return true
 }
+
+// TokenState is the last known rate limit of a pooled GitHub token, per resource, e.g. "core" or "search".
+type TokenState struct {
+	Token     string               `json:"token"`
+	Resources map[string]RateLimit `json:"resources"`
+}
+
+type RateLimit struct {
+	Remaining int       `json:"remaining"`
+	Limit     int       `json:"limit"`
+	Reset     time.Time `json:"reset"`
+}
+
+// CacheStats describes the HTTP cache of conditional GitHub requests.
+type CacheStats struct {
+	Entries  int   `json:"entries"`
+	Bytes    int64 `json:"bytes"`
+	MaxBytes int64 `json:"max_bytes"`
+	Hits     int64 `json:"hits"`
+	Misses   int64 `json:"misses"`
+}
@@ -0,0 +1,109 @@
+package model
+
+import "testing"
+
+// validQuery returns a query passing Validate, changed by modify.
+func validQuery(modify func(q *QueryParameters)) *QueryParameters {
+	q := &QueryParameters{
+		FirstCreationDate: "2020-01-01",
+		LastCreationDate:  "2020-12-31",
+		Language:          Languages{"Go"},
+		MinStars:          "10",
+		MaxStars:          "100",
+		Order:             "desc",
+	}
+
+	modify(q)
+
+	return q
+}
+
+func TestValidateLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		fields   Fields
+		want     bool
+	}{
+		{name: "linguist name", language: "Go", want: true},
+		{name: "alias", language: "golang", want: true},
+		{name: "alias with lines of code", language: "golang", fields: Fields{FieldSelfWrittenLOC}, want: true},
+		{name: "shell alias with lines of code", language: "bash", fields: Fields{FieldThirdPartyLOC}, want: true},
+		{name: "unknown", language: "NotALanguage", want: false},
+		{name: "uncountable without lines of code", language: "GDScript", fields: Fields{FieldOpenIssues}, want: true},
+		{name: "uncountable with lines of code", language: "GDScript", fields: Fields{FieldSelfWrittenLOC}, want: false},
+		// No fields select every field, the lines of code included.
+		{name: "uncountable with every field", language: "GDScript", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := validQuery(func(q *QueryParameters) {
+				q.Language = Languages{tt.language}
+				q.Fields = tt.fields
+			})
+
+			if got := q.Validate(); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateStars(t *testing.T) {
+	tests := []struct {
+		minStars string
+		maxStars string
+		want     bool
+	}{
+		{minStars: "10", maxStars: "100", want: true},
+		{minStars: "0", maxStars: "0", want: true},
+		{minStars: "42", maxStars: "42", want: true},
+		{minStars: "100", maxStars: "10", want: false},
+		{minStars: "-1", maxStars: "10", want: false},
+		{minStars: "0", maxStars: "-1", want: false},
+		{minStars: "", maxStars: "10", want: false},
+		{minStars: "10", maxStars: "many", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.minStars+".."+tt.maxStars, func(t *testing.T) {
+			q := validQuery(func(q *QueryParameters) {
+				q.MinStars = tt.minStars
+				q.MaxStars = tt.maxStars
+			})
+
+			if got := q.Validate(); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		archived string
+		isFork   string
+		want     bool
+	}{
+		{name: "omitted", want: true},
+		{name: "booleans", archived: "false", isFork: "true", want: true},
+		{name: "only forks", isFork: ForkOnly, want: true},
+		{name: "only archived", archived: "only", want: false},
+		{name: "invalid fork", isFork: "some", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := validQuery(func(q *QueryParameters) {
+				q.Archived = tt.archived
+				q.IsFork = tt.isFork
+			})
+
+			if got := q.Validate(); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
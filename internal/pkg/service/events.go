@@ -0,0 +1,76 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/haapjari/repository-search-api/internal/pkg/model"
+)
+
+// eventBufferSize is the number of events a subscriber may fall behind before events are dropped for it. Dropping
+// keeps a slow client from stalling the workers.
+const eventBufferSize = 64
+
+// eventBroker fans progress events of a search out to any number of subscribers.
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan model.ProgressEvent]struct{}
+	closed      bool
+}
+
+// subscribe returns a channel receiving every event published from now on, and a function to unsubscribe. The channel
+// is closed when the broker is closed, or immediately if it already has been.
+func (b *eventBroker) subscribe() (<-chan model.ProgressEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan model.ProgressEvent, eventBufferSize)
+
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan model.ProgressEvent]struct{})
+	}
+
+	b.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *eventBroker) publish(e model.ProgressEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func (b *eventBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.closed = true
+
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+	"github.com/haapjari/repository-search-api/internal/pkg/util"
+)
+
+// repositoryMetricsFragment selects every metric of repoMetadata with totalCount fields, so none of the connections
+// have to be paginated. Closed pull requests include the merged ones, like the "is:pr is:closed" search does.
+const repositoryMetricsFragment = `fragment RepositoryMetrics on Repository {
+  openIssues: issues(states: OPEN) { totalCount }
+  closedIssues: issues(states: CLOSED) { totalCount }
+  openPullRequests: pullRequests(states: OPEN) { totalCount }
+  closedPullRequests: pullRequests(states: [CLOSED, MERGED]) { totalCount }
+  mergedPullRequests: pullRequests(states: MERGED) { totalCount }
+  releases { totalCount }
+  latestRelease { publishedAt }
+  defaultBranchRef { target { ... on Commit { history { totalCount } } } }
+}`
+
+type graphqlRequest struct {
+	Query     string            `json:"query"`
+	Variables map[string]string `json:"variables"`
+}
+
+type graphqlResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []graphqlError             `json:"errors"`
+}
+
+type graphqlError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Path    []any  `json:"path"`
+}
+
+type graphqlRateLimit struct {
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"resetAt"`
+}
+
+type graphqlCount struct {
+	TotalCount int `json:"totalCount"`
+}
+
+type graphqlRepository struct {
+	OpenIssues         graphqlCount `json:"openIssues"`
+	ClosedIssues       graphqlCount `json:"closedIssues"`
+	OpenPullRequests   graphqlCount `json:"openPullRequests"`
+	ClosedPullRequests graphqlCount `json:"closedPullRequests"`
+	MergedPullRequests graphqlCount `json:"mergedPullRequests"`
+	Releases           graphqlCount `json:"releases"`
+	LatestRelease      *struct {
+		PublishedAt time.Time `json:"publishedAt"`
+	} `json:"latestRelease"`
+	DefaultBranchRef *struct {
+		Target struct {
+			History *graphqlCount `json:"history"`
+		} `json:"target"`
+	} `json:"defaultBranchRef"`
+}
+
+// graphqlMetadata is a method of the RepositoryService struct. It reads the metadata of a batch of repositories with a
+// single query to the GitHub GraphQL API (https://docs.github.com/en/graphql), using an alias per repository. The
+// result is keyed by full name. Repositories the query could not resolve are left out of the result.
+func (rs *RepositoryService) graphqlMetadata(ctx context.Context, batch []*github.Repository) (map[string]*repoMetadata, error) {
+	var query strings.Builder
+
+	variables := make(map[string]string, len(batch)*2)
+	params := make([]string, 0, len(batch)*2)
+
+	for i, r := range batch {
+		owner, name := strings.Split(r.GetFullName(), "/")[0], strings.Split(r.GetFullName(), "/")[1]
+
+		variables[fmt.Sprintf("o%d", i)] = owner
+		variables[fmt.Sprintf("n%d", i)] = name
+		params = append(params, fmt.Sprintf("$o%d: String!, $n%d: String!", i, i))
+
+		_, _ = fmt.Fprintf(&query, "  r%d: repository(owner: $o%d, name: $n%d) { ...RepositoryMetrics }\n", i, i, i)
+	}
+
+	body, err := json.Marshal(&graphqlRequest{
+		Query: fmt.Sprintf("query(%s) {\n%s  rateLimit { remaining resetAt }\n}\n%s",
+			strings.Join(params, ", "), query.String(), repositoryMetricsFragment),
+		Variables: variables,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; {
+		t, err := rs.tokens.next(ctx, resourceGraphQL)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, httpResp, err := rs.graphqlDo(ctx, t.client, body)
+		if err != nil {
+			delay, ok := retryDelay(err, httpResp, attempt)
+			if !ok || attempt >= rs.retryCount {
+				return nil, err
+			}
+
+			attempt++
+			slog.Warn(fmt.Sprintf("retrying a GraphQL query | Attempt: %v/%v | Delay: %v | Error: %v", attempt, rs.retryCount, delay, err))
+
+			if err = sleep(ctx, delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var rateLimit graphqlRateLimit
+		if raw, ok := resp.Data["rateLimit"]; ok {
+			if err = json.Unmarshal(raw, &rateLimit); err == nil {
+				rs.rateLimit.Store(int64(rateLimit.Remaining))
+			}
+		}
+
+		// The next token is picked based on the budget, so an exhausted token is skipped until it resets.
+		if rateLimited(resp.Errors) {
+			reset := rateLimit.ResetAt
+			if reset.IsZero() {
+				reset = time.Now().Add(time.Minute)
+			}
+
+			t.setRate(resourceGraphQL, github.Rate{Reset: github.Timestamp{Time: reset}})
+			continue
+		}
+
+		t.setRate(resourceGraphQL, github.Rate{Remaining: rateLimit.Remaining, Reset: github.Timestamp{Time: rateLimit.ResetAt}})
+
+		for _, e := range resp.Errors {
+			slog.Warn(fmt.Sprintf("GraphQL error | Path: %v | Type: %v | Message: %v", e.Path, e.Type, e.Message))
+		}
+
+		slog.Debug(fmt.Sprintf("POST /graphql | Repositories: %v | Rate Limit Left: %v", len(batch), rateLimit.Remaining))
+
+		result := make(map[string]*repoMetadata, len(batch))
+
+		for i, r := range batch {
+			raw, ok := resp.Data[fmt.Sprintf("r%d", i)]
+			if !ok || string(raw) == "null" {
+				continue
+			}
+
+			var repo graphqlRepository
+			if err = json.Unmarshal(raw, &repo); err != nil {
+				return nil, util.Error(fmt.Errorf("unable to decode the GraphQL response: %v", err))
+			}
+
+			m := &repoMetadata{
+				openIssues:         repo.OpenIssues.TotalCount,
+				closedIssues:       repo.ClosedIssues.TotalCount,
+				openPullRequests:   repo.OpenPullRequests.TotalCount,
+				closedPullRequests: repo.ClosedPullRequests.TotalCount,
+				mergedPullRequests: repo.MergedPullRequests.TotalCount,
+				releases:           repo.Releases.TotalCount,
+			}
+
+			if repo.LatestRelease != nil {
+				m.latestRelease = repo.LatestRelease.PublishedAt
+			}
+
+			// An empty repository has no default branch, and therefore no commits.
+			if repo.DefaultBranchRef != nil && repo.DefaultBranchRef.Target.History != nil {
+				m.commitCount = repo.DefaultBranchRef.Target.History.TotalCount
+			}
+
+			result[r.GetFullName()] = m
+		}
+
+		return result, nil
+	}
+}
+
+// graphqlDo is a method of the RepositoryService struct. It posts a GraphQL request with the authenticated HTTP client
+// of the given GitHub client. The HTTP response is returned alongside errors, so the caller can decide whether to
+// retry; its body has already been closed.
+func (rs *RepositoryService) graphqlDo(ctx context.Context, client *github.Client, body []byte) (*graphqlResponse, *http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, graphqlURL(client), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Client().Do(req)
+	if err != nil {
+		return nil, nil, util.Error(fmt.Errorf("unable to query the GraphQL API: %v", err))
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, resp, util.Error(fmt.Errorf("unable to query the GraphQL API: %v: %s", resp.Status, b))
+	}
+
+	var result graphqlResponse
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, resp, util.Error(fmt.Errorf("unable to decode the GraphQL response: %v", err))
+	}
+
+	return &result, resp, nil
+}
+
+// graphqlURL returns the GraphQL endpoint matching the REST base URL of the client, which is /graphql on github.com and
+// /api/graphql on GitHub Enterprise Server.
+func graphqlURL(client *github.Client) string {
+	u := *client.BaseURL
+
+	if strings.HasSuffix(u.Path, "/api/v3/") {
+		u.Path = strings.TrimSuffix(u.Path, "v3/") + "graphql"
+	} else {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/graphql"
+	}
+
+	return u.String()
+}
+
+func rateLimited(errs []graphqlError) bool {
+	for _, e := range errs {
+		if e.Type == "RATE_LIMITED" {
+			return true
+		}
+	}
+
+	return false
+}
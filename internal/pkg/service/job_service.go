@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/haapjari/repository-search-api/internal/pkg/cfg"
+	"github.com/haapjari/repository-search-api/internal/pkg/model"
+	"github.com/haapjari/repository-search-api/internal/pkg/store"
+)
+
+var (
+	// ErrJobNotFound is returned when a job with the requested ID does not exist.
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobNotFinished is returned when the results of a job are requested before the job has completed.
+	ErrJobNotFinished = errors.New("job has not finished")
+	// ErrJobForbidden is returned when a job is requested by someone else than the owner who submitted it.
+	ErrJobForbidden = errors.New("job belongs to other credentials")
+)
+
+type job struct {
+	model.Job
+
+	// owner identifies who submitted the job, e.g. a hash of the credentials of the search, as the results may include
+	// private repositories only the owner can see.
+	owner string
+
+	// svc is only kept while the job is running, so a finished job does not hold on to the tokens of its search.
+	svc    *RepositoryService
+	result *model.RepositoryResponse
+}
+
+// JobService runs searches in the background. Finished jobs, and their results, are kept for the TTL of the
+// configuration and removed afterwards. Every job belongs to the owner who submitted it, and is only returned to the
+// same owner.
+type JobService struct {
+	mu     sync.Mutex
+	jobs   map[string]*job
+	config *cfg.Config
+	store  *store.Store
+	ttl    time.Duration
+}
+
+func NewJobService(conf *cfg.Config, st *store.Store) *JobService {
+	return &JobService{
+		jobs:   make(map[string]*job),
+		config: conf,
+		store:  st,
+		ttl:    conf.JobTTL,
+	}
+}
+
+// Submit is a method of the JobService struct. It starts a new search of the given owner in the background and returns
+// the job describing it immediately.
+func (js *JobService) Submit(tokens *TokenPool, params *model.QueryParameters, owner string) (*model.Job, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	svc := NewRepositoryService(js.config, js.store, tokens, params)
+
+	j := &job{
+		Job: model.Job{
+			ID:        id,
+			Status:    model.JobStatusRunning,
+			Query:     params,
+			CreatedAt: time.Now().UTC(),
+		},
+		owner: owner,
+		svc:   svc,
+	}
+
+	js.mu.Lock()
+	js.expire(time.Now())
+	js.jobs[id] = j
+	js.mu.Unlock()
+
+	go js.run(j, svc)
+
+	return js.Get(id, owner)
+}
+
+// Get is a method of the JobService struct. It returns a snapshot of the job with the given ID, if it belongs to the
+// given owner.
+func (js *JobService) Get(id string, owner string) (*model.Job, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	j, err := js.lookup(id, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := j.Job
+	if j.svc != nil {
+		snapshot.Progress = j.svc.Progress()
+	}
+
+	return &snapshot, nil
+}
+
+// Result is a method of the JobService struct. It returns the results of a completed job of the given owner.
+func (js *JobService) Result(id string, owner string) (*model.RepositoryResponse, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	j, err := js.lookup(id, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if j.Status != model.JobStatusCompleted {
+		return nil, ErrJobNotFinished
+	}
+
+	return j.result, nil
+}
+
+// Events is a method of the JobService struct. It subscribes to the progress events of the job with the given ID of
+// the given owner. The returned channel is closed once the job has finished or has been cancelled.
+func (js *JobService) Events(id string, owner string) (<-chan model.ProgressEvent, func(), error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	j, err := js.lookup(id, owner)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A finished job has no more events.
+	if j.svc == nil {
+		events := make(chan model.ProgressEvent)
+		close(events)
+
+		return events, func() {}, nil
+	}
+
+	events, unsubscribe := j.svc.Subscribe()
+
+	return events, unsubscribe, nil
+}
+
+// Cancel is a method of the JobService struct. It stops a running job of the given owner. Jobs that have already
+// finished are removed from the service instead. The returned boolean reports whether the job was still running.
+func (js *JobService) Cancel(id string, owner string) (bool, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	j, err := js.lookup(id, owner)
+	if err != nil {
+		return false, err
+	}
+
+	if j.Status != model.JobStatusRunning {
+		delete(js.jobs, id)
+		return false, nil
+	}
+
+	j.svc.Stop()
+	js.finish(j, model.JobStatusCancelled, nil)
+
+	return true, nil
+}
+
+// run is a method of the JobService struct. It executes the search of a job with svc and records the outcome. The
+// service is stopped only afterwards, so subscribers to the job events observe the final state once their channel
+// closes.
+func (js *JobService) run(j *job, svc *RepositoryService) {
+	repos, err := svc.Query(context.Background())
+
+	defer svc.Stop()
+
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	// The job may have been cancelled while the query was running.
+	if j.Status != model.JobStatusRunning {
+		return
+	}
+
+	if err != nil {
+		js.finish(j, model.JobStatusFailed, err)
+		return
+	}
+
+	j.result = &model.RepositoryResponse{
+		TotalCount:     svc.TotalCount(),
+		RetrievedCount: svc.Progress().Discovered,
+		Items:          repos,
+	}
+
+	js.finish(j, model.JobStatusCompleted, nil)
+}
+
+// finish is a method of the JobService struct. It moves a job into a terminal state and releases its service, keeping
+// only the final progress. The caller must hold the lock.
+func (js *JobService) finish(j *job, status model.JobStatus, err error) {
+	now := time.Now().UTC()
+
+	j.Status = status
+	j.FinishedAt = &now
+	j.Progress = j.svc.Progress()
+	j.svc = nil
+
+	if err != nil {
+		j.Error = err.Error()
+	}
+}
+
+// lookup is a method of the JobService struct. It returns the job with the given ID, after removing the expired jobs,
+// if it belongs to the given owner. The caller must hold the lock.
+func (js *JobService) lookup(id string, owner string) (*job, error) {
+	js.expire(time.Now())
+
+	j, ok := js.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	if subtle.ConstantTimeCompare([]byte(j.owner), []byte(owner)) != 1 {
+		return nil, ErrJobForbidden
+	}
+
+	return j, nil
+}
+
+// expire is a method of the JobService struct. It removes the jobs that finished longer than the TTL ago. A TTL of 0
+// keeps finished jobs until they are deleted. The caller must hold the lock.
+func (js *JobService) expire(now time.Time) {
+	if js.ttl <= 0 {
+		return
+	}
+
+	for id, j := range js.jobs {
+		if j.FinishedAt != nil && now.After(j.FinishedAt.Add(js.ttl)) {
+			delete(js.jobs, id)
+		}
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
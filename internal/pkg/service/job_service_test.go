@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/haapjari/repository-search-api/internal/pkg/cfg"
+	"github.com/haapjari/repository-search-api/internal/pkg/model"
+)
+
+func TestJobServiceOwner(t *testing.T) {
+	js := NewJobService(&cfg.Config{JobTTL: time.Hour}, nil)
+
+	finished := time.Now().UTC()
+	js.jobs["job"] = &job{
+		Job: model.Job{
+			ID:         "job",
+			Status:     model.JobStatusCompleted,
+			FinishedAt: &finished,
+		},
+		owner:  "owner",
+		result: &model.RepositoryResponse{TotalCount: 1},
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		owner   string
+		wantErr error
+	}{
+		{name: "owner", id: "job", owner: "owner"},
+		{name: "other credentials", id: "job", owner: "other", wantErr: ErrJobForbidden},
+		{name: "no credentials", id: "job", owner: "", wantErr: ErrJobForbidden},
+		{name: "unknown job", id: "unknown", owner: "owner", wantErr: ErrJobNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := js.Get(tt.id, tt.owner); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Get() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if _, err := js.Result(tt.id, tt.owner); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Result() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if _, _, err := js.Events(tt.id, tt.owner); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Events() error = %v, want %v", err, tt.wantErr)
+			}
+
+			// Cancelling removes a finished job, so only other owners are tried.
+			if tt.wantErr != nil {
+				if _, err := js.Cancel(tt.id, tt.owner); !errors.Is(err, tt.wantErr) {
+					t.Errorf("Cancel() error = %v, want %v", err, tt.wantErr)
+				}
+			}
+		})
+	}
+
+	if _, err := js.Cancel("job", "owner"); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+
+	if _, err := js.Get("job", "owner"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Get() of a removed job error = %v, want %v", err, ErrJobNotFound)
+	}
+}
@@ -1,40 +1,82 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 
 	"github.com/google/go-github/v61/github"
+	"github.com/haapjari/repository-search-api/internal/pkg/cfg"
 	"github.com/haapjari/repository-search-api/internal/pkg/model"
+	"github.com/haapjari/repository-search-api/internal/pkg/store"
 	"github.com/haapjari/repository-search-api/internal/pkg/util"
+	"github.com/haapjari/repository-search-api/pkg/dependency"
 )
 
+// ErrStopped is returned by Query when the service is stopped before every repository has been processed.
+var ErrStopped = errors.New("repository service stopped")
+
 type RepositoryService struct {
 	QueryParameters *model.QueryParameters
 
-	token      string
+	tokens     *TokenPool
 	stop       chan struct{}
+	stopOnce   sync.Once
 	errorCh    chan error
 	completed  chan *model.Repository
 	retryCount int
-	*github.Client
+	discovered atomic.Int64
+	processed  atomic.Int64
+	failed     atomic.Int64
+	errorsMu   sync.Mutex
+	errors     []string
+	events     eventBroker
+	startTime  time.Time
+	rateLimit  atomic.Int64
+	totalCount atomic.Int64
+	store      *store.Store
+	workers    int
+	apiSlots   chan struct{}
+	cloneSlots chan struct{}
+	backend    string
+	batchSize  int
+
+	// rank is the position of every repository in the search results, written once before the workers start.
+	rank map[string]int
 }
 
-func NewRepositoryService(token string, params *model.QueryParameters) *RepositoryService {
+// NewRepositoryService creates a service for a single search, calling GitHub with the tokens of the pool. Computed
+// repositories are read from and written to st, which may be nil to always compute everything from scratch.
+func NewRepositoryService(conf *cfg.Config, st *store.Store, tokens *TokenPool, params *model.QueryParameters) *RepositoryService {
 	g := &RepositoryService{
 		QueryParameters: params,
-		token:           token,
+		tokens:          tokens,
+		store:           st,
 		errorCh:         make(chan error),
 		stop:            make(chan struct{}),
 		retryCount:      5,
-		Client:          github.NewClient(nil).WithAuthToken(strings.Split(token, " ")[1]),
+		startTime:       time.Now(),
+		workers:         max(conf.WorkerCount, 1),
+		apiSlots:        make(chan struct{}, max(conf.APIConcurrency, 1)),
+		cloneSlots:      make(chan struct{}, max(conf.CloneConcurrency, 1)),
+		backend:         conf.Backend,
+		batchSize:       max(conf.GraphQLBatchSize, 1),
 	}
 
+	g.rateLimit.Store(-1)
+
 	go g.errorHandler()
 
 	return g
@@ -42,30 +84,250 @@ func NewRepositoryService(token string, params *model.QueryParameters) *Reposito
 
 // Query is a method of the RepositoryService struct. It queries GitHub repositories based on the provided query parameters.
 // It retrieves detailed information about the repositories and returns the result as a slice of model.Repository structs.
-func (rs *RepositoryService) Query() ([]*model.Repository, error) {
-	repos, err := rs.multiRepoSearch()
+func (rs *RepositoryService) Query(ctx context.Context) ([]*model.Repository, error) {
+	var result []*model.Repository
+
+	err := rs.Stream(ctx, func(repo *model.Repository) {
+		result = append(result, repo)
+	})
+
+	// The workers finish in any order, so restore the order of the search.
+	slices.SortStableFunc(result, func(a, b *model.Repository) int {
+		return cmp.Compare(rs.rank[a.FullName], rs.rank[b.FullName])
+	})
+
+	return result, err
+}
+
+// Stream is a method of the RepositoryService struct. It queries GitHub repositories like Query, but instead of
+// collecting the results it calls fn with each repository as soon as a worker has finished processing it.
+//
+// The repositories are processed by a pool of workers. The number of workers talking to the GitHub API and the number
+// of workers cloning and analysing repositories at the same time are limited separately.
+//
+// Cancelling ctx stops the service, and stopping the service cancels every GitHub call, clone and dependency download
+// still in flight.
+func (rs *RepositoryService) Stream(ctx context.Context, fn func(*model.Repository)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	finished := make(chan struct{})
+	defer close(finished)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			rs.Stop()
+		case <-rs.stop:
+			cancel()
+		case <-finished:
+		}
+	}()
+
+	rs.emit("", model.StageSearch, time.Time{})
+
+	repos, err := rs.multiRepoSearch(ctx)
 	if err != nil {
-		return nil, util.Error(err)
+		return util.Error(err)
 	}
 
-	result := make([]*model.Repository, len(repos))
+	rs.discovered.Store(int64(len(repos)))
 	rs.completed = make(chan *model.Repository, len(repos))
 
-	for _, r := range repos {
-		rs.worker(r)
+	queue := make(chan *task)
+
+	var wg sync.WaitGroup
+
+	for range rs.workers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for t := range queue {
+				rs.worker(ctx, t.repo, t.meta)
+			}
+		}()
+	}
+
+	go func() {
+		defer func() {
+			close(queue)
+			wg.Wait()
+			close(rs.completed)
+		}()
+
+		for batch := range slices.Chunk(repos, rs.batchSize) {
+			metadata := rs.prefetch(ctx, batch)
+
+			for _, r := range batch {
+				select {
+				case <-rs.stop:
+					return
+				case queue <- &task{repo: r, meta: metadata[r.GetFullName()]}:
+				}
+			}
+		}
+	}()
+
+	for repo := range rs.completed {
+		fn(repo)
 	}
 
-	for range len(repos) {
-		result = append(result, <-rs.completed)
+	rs.emit("", model.StageDone, time.Time{})
+
+	if rs.stopped() {
+		return ErrStopped
 	}
 
-	return result, nil
+	return nil
+}
+
+// task is a repository waiting for a worker, with its metadata if it has been prefetched.
+type task struct {
+	repo *github.Repository
+	meta *repoMetadata
 }
 
-// Stop is a method of the RepositoryService struct. It stops the service by closing the stop channel.
+// prefetch is a method of the RepositoryService struct. With the GraphQL backend, it reads the metadata of a batch of
+// repositories in a single request. It returns nil with the REST backend or when no metadata field is selected, or if
+// the request fails, in which case the workers fall back to the REST API.
+func (rs *RepositoryService) prefetch(ctx context.Context, batch []*github.Repository) map[string]*repoMetadata {
+	if rs.backend != cfg.BackendGraphQL || !rs.QueryParameters.Fields.Has(metadataFields...) {
+		return nil
+	}
+
+	release, ok := rs.acquire(rs.apiSlots)
+	if !ok {
+		return nil
+	}
+	defer release()
+
+	rs.emit("", model.StageMetadata, time.Time{})
+
+	metadata, err := rs.graphqlMetadata(ctx, batch)
+	if err != nil {
+		slog.Warn("unable to read the metadata with GraphQL, falling back to REST: " + err.Error())
+		return nil
+	}
+
+	return metadata
+}
+
+// Stop is a method of the RepositoryService struct. It stops the service by closing the stop channel. It is safe to
+// call Stop more than once.
 func (rs *RepositoryService) Stop() {
-	if rs.stop != nil {
+	rs.stopOnce.Do(func() {
 		close(rs.stop)
+		rs.events.close()
+	})
+}
+
+// Subscribe is a method of the RepositoryService struct. It returns a channel of progress events of the search, and a
+// function to unsubscribe. The channel is closed when the service is stopped.
+func (rs *RepositoryService) Subscribe() (<-chan model.ProgressEvent, func()) {
+	return rs.events.subscribe()
+}
+
+// Progress is a method of the RepositoryService struct. It returns how many repositories the search discovered and how
+// many of them have been processed so far.
+func (rs *RepositoryService) Progress() model.Progress {
+	return model.Progress{
+		Discovered: int(rs.discovered.Load()),
+		Processed:  int(rs.processed.Load()),
+		Failed:     int(rs.failed.Load()),
+	}
+}
+
+// TotalCount is a method of the RepositoryService struct. It returns GitHub's total_count of the search, which may be
+// larger than the number of repositories actually retrieved.
+func (rs *RepositoryService) TotalCount() int {
+	return int(rs.totalCount.Load())
+}
+
+// Errors is a method of the RepositoryService struct. It returns the messages of the errors that occurred while
+// processing the repositories.
+func (rs *RepositoryService) Errors() []string {
+	rs.errorsMu.Lock()
+	defer rs.errorsMu.Unlock()
+
+	errs := make([]string, len(rs.errors))
+	copy(errs, rs.errors)
+
+	return errs
+}
+
+// stopped is a method of the RepositoryService struct. It reports whether Stop has been called.
+func (rs *RepositoryService) stopped() bool {
+	select {
+	case <-rs.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// report is a method of the RepositoryService struct. It records an error and forwards it to the error handler, unless
+// the service has already been stopped, in which case nobody is listening anymore.
+func (rs *RepositoryService) report(err error) {
+	rs.errorsMu.Lock()
+	rs.errors = append(rs.errors, err.Error())
+	rs.errorsMu.Unlock()
+
+	select {
+	case <-rs.stop:
+		slog.Error(err.Error())
+	case rs.errorCh <- err:
+	}
+}
+
+// sleep waits for the given duration, or until ctx is cancelled.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// acquire is a method of the RepositoryService struct. It blocks until one of the given slots is free and returns a
+// function releasing it, or returns false if the service was stopped while waiting.
+func (rs *RepositoryService) acquire(slots chan struct{}) (func(), bool) {
+	select {
+	case <-rs.stop:
+		return nil, false
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	}
+}
+
+// emit is a method of the RepositoryService struct. It publishes a progress event for the given repository and stage.
+// The zero repoStart is used for events that do not belong to a single repository.
+func (rs *RepositoryService) emit(repo string, stage model.Stage, repoStart time.Time) {
+	e := model.ProgressEvent{
+		Repository:         repo,
+		Stage:              stage,
+		Elapsed:            time.Since(rs.startTime).Seconds(),
+		RateLimitRemaining: int(rs.rateLimit.Load()),
+		Progress:           rs.Progress(),
+		Time:               time.Now().UTC(),
+	}
+
+	if !repoStart.IsZero() {
+		e.RepositoryElapsed = time.Since(repoStart).Seconds()
+	}
+
+	rs.events.publish(e)
+}
+
+// observe is a method of the RepositoryService struct. It records the rate limit reported by a GitHub response.
+func (rs *RepositoryService) observe(resp *github.Response) {
+	if resp != nil {
+		rs.rateLimit.Store(int64(resp.Rate.Remaining))
 	}
 }
 
@@ -84,7 +346,8 @@ func (rs *RepositoryService) errorHandler() {
 
 // worker is a method of the RepositoryService struct. It processes a GitHub repository based on the provided repository
 // information. It retrieves detailed information about the repository and sends the result to the completed channel.
-func (rs *RepositoryService) worker(r *github.Repository) {
+// The metadata is fetched with the REST API, unless meta has already been prefetched.
+func (rs *RepositoryService) worker(ctx context.Context, r *github.Repository, meta *repoMetadata) {
 	select {
 	case <-rs.stop:
 		return
@@ -93,121 +356,398 @@ func (rs *RepositoryService) worker(r *github.Repository) {
 
 		startTime := time.Now()
 
-		pullRequests, err := rs.repoPulls(r.GetFullName())
-		if err != nil {
-			rs.errorCh <- err
-		}
+		fields := rs.QueryParameters.Fields
 
-		issues, err := rs.repoIssues(r.GetFullName())
-		if err != nil {
-			rs.errorCh <- err
-		}
+		// compute are the fields the stages compute. A stored repository only saves the stages of the stored fields, the
+		// fields of the search results, the releases and the contributors are always fresh.
+		compute := fields
 
-		openPullRequestCount := 0
-		closedPullRequestCount := 0
+		var cached *model.Repository
 
-		for _, pr := range pullRequests {
-			if pr.GetState() == "open" {
-				openPullRequestCount++
-			}
+		if rs.store != nil && !rs.QueryParameters.Refresh {
+			if repo, ok := rs.store.Get(r.GetFullName(), r.GetPushedAt().Time); ok {
+				cached = repo
+				compute = fields.Without(storedFields...)
+
+				rs.emit(r.GetFullName(), model.StageCached, startTime)
 
-			if pr.GetState() == "closed" {
-				closedPullRequestCount++
+				slog.Debug("Served From Store: " + r.GetFullName())
 			}
 		}
 
-		commits, err := rs.repoCommits(r.GetFullName())
-
-		if err != nil {
-			rs.errorCh <- err
+		failed := false
+		fail := func(err error) {
+			failed = true
+			rs.report(err)
 		}
 
-		releases, err := rs.repoLatestRelease(r.GetFullName())
-		if err != nil {
-			rs.errorCh <- err
+		// Only the stages computing a selected field run, the others leave their fields at zero.
+		m := meta
+		if m == nil {
+			m = &repoMetadata{}
 		}
 
-		latestRelease, err := rs.repositoryLatestRelease(r.GetFullName())
-		if err != nil {
-			rs.errorCh <- err
+		var (
+			contributors []*github.Contributor
+			err          error
+		)
 
-		}
+		if (meta == nil && compute.Has(metadataFields...)) || compute.Has(model.FieldContributorCount) {
+			release, ok := rs.acquire(rs.apiSlots)
+			if !ok {
+				return
+			}
 
-		contributors, err := rs.repoContributors(r.GetFullName())
-		if err != nil {
-			rs.errorCh <- err
-		}
+			// Metadata prefetched with GraphQL is used as is, anything else falls back to the REST API.
+			if meta == nil && compute.Has(metadataFields...) {
+				m = rs.restMetadata(ctx, r.GetFullName(), compute, startTime, fail)
+			}
 
-		path, err := util.Clone(rs.token, r.GetCloneURL())
-		if err != nil {
-			rs.errorCh <- err
-		}
+			if compute.Has(model.FieldContributorCount) {
+				rs.emit(r.GetFullName(), model.StageContributors, startTime)
+				if contributors, err = rs.repoContributors(ctx, r.GetFullName()); err != nil {
+					fail(err)
+				}
+			}
 
-		selfWrittenLOC, err := util.CalcLOC(path, r.GetLanguage())
-		if err != nil {
-			rs.errorCh <- err
+			release()
 		}
 
-		libs, err := util.ParseModFile(path)
-		if err != nil {
-			rs.errorCh <- err
+		commitCount, commitErr := m.commitCount, m.commitErr
+		commitCountMethod := model.CommitCountMethodAPI
+
+		if cached != nil {
+			commitCount, commitErr, commitCountMethod = cached.CommitCount, nil, cached.CommitCountMethod
 		}
 
-		err = os.RemoveAll(path)
-		if err != nil {
-			rs.errorCh <- err
+		// A cancelled search does not deliver repositories with metrics missing because of the cancellation.
+		if ctx.Err() != nil {
+			return
 		}
 
+		selfWrittenLOC := 0
 		thirdPartyLOC := 0
+		thirdParty := compute.Has(model.FieldThirdPartyLOC, model.FieldUnresolvedDependencies)
 
-		for _, lib := range libs {
-			slog.Debug(fmt.Sprintf("Processing %v | Library: %v", r.GetFullName(), lib))
+		var unresolved []string
 
-			p, fetchErr := util.FetchLibrary(lib)
-			if fetchErr != nil {
-				rs.errorCh <- fetchErr
+		if compute.Has(model.FieldSelfWrittenLOC) || thirdParty || commitErr != nil {
+			release, ok := rs.acquire(rs.cloneSlots)
+			if !ok {
+				return
 			}
 
-			l, calcErr := util.CalcLOC(p, r.GetLanguage())
-			if calcErr != nil {
-				rs.errorCh <- calcErr
+			rs.emit(r.GetFullName(), model.StageClone, startTime)
+			path, cloneErr := rs.clone(ctx, r.GetCloneURL())
+			if cloneErr != nil {
+				fail(cloneErr)
 			}
 
-			thirdPartyLOC += l
+			var (
+				resolver dependency.DependencyResolver
+				deps     []dependency.Dependency
+			)
+
+			if path != "" {
+				if compute.Has(model.FieldSelfWrittenLOC) {
+					rs.emit(r.GetFullName(), model.StageLOC, startTime)
+					if selfWrittenLOC, err = util.CalcLOC(path, r.GetLanguage()); err != nil {
+						fail(err)
+					}
+				}
+
+				// Fall back to counting the commits of the clone if the API could not count them.
+				if commitErr != nil {
+					if commitCount, err = util.CountCommits(path); err == nil {
+						commitErr = nil
+						commitCountMethod = model.CommitCountMethodClone
+					}
+				}
+
+				if thirdParty {
+					resolver = dependency.Lookup(r.GetLanguage(), path)
+				}
+
+				if resolver != nil {
+					if deps, unresolved, err = resolver.Dependencies(ctx, path); err != nil {
+						fail(err)
+					}
+				}
+
+				if err = os.RemoveAll(path); err != nil {
+					fail(err)
+				}
+			}
+
+			if thirdParty {
+				rs.emit(r.GetFullName(), model.StageThirdPartyLOC, startTime)
+			}
+
+			if resolver != nil {
+				loc, missing := rs.dependencyLOC(ctx, r.GetFullName(), resolver, deps, fail)
+				thirdPartyLOC += loc
+				unresolved = append(unresolved, missing...)
+			}
+
+			release()
+		}
+
+		if commitErr != nil {
+			fail(commitErr)
+			commitCountMethod = ""
+		}
+
+		if ctx.Err() != nil {
+			return
 		}
 
-		rs.completed <- &model.Repository{
+		repo := &model.Repository{
 			Name:                   r.GetName(),
 			FullName:               r.GetFullName(),
 			CreatedAt:              r.GetCreatedAt().Format("2006-01-02"),
 			StargazerCount:         r.GetStargazersCount(),
 			Language:               r.GetLanguage(),
-			OpenIssues:             r.GetOpenIssues(),
-			ClosedIssues:           len(issues),
-			OpenPullRequestCount:   openPullRequestCount,
-			ClosedPullRequestCount: closedPullRequestCount,
+			OpenIssues:             m.openIssues,
+			ClosedIssues:           m.closedIssues,
+			OpenPullRequestCount:   m.openPullRequests,
+			ClosedPullRequestCount: m.closedPullRequests,
+			MergedPullRequestCount: m.mergedPullRequests,
 			Forks:                  r.GetForksCount(),
 			WatcherCount:           r.GetWatchersCount(),
 			SubscriberCount:        r.GetSubscribersCount(),
-			CommitCount:            len(commits),
+			CommitCount:            commitCount,
+			CommitCountMethod:      commitCountMethod,
 			NetworkCount:           r.GetNetworkCount(),
-			LatestRelease:          latestRelease.GetPublishedAt().Format("2006-01-02"),
-			TotalReleasesCount:     len(releases),
+			LatestRelease:          m.latestRelease.Format("2006-01-02"),
+			TotalReleasesCount:     m.releases,
 			ContributorCount:       len(contributors),
 			ThirdPartyLOC:          thirdPartyLOC,
 			SelfWrittenLOC:         selfWrittenLOC,
+			UnresolvedDependencies: unresolved,
 		}
 
+		if cached != nil {
+			// Issue and pull request counts prefetched with GraphQL cost nothing extra, so only the REST backend, which
+			// spends a search API call on each of them, serves them from the store.
+			if meta == nil {
+				repo.OpenIssues = cached.OpenIssues
+				repo.ClosedIssues = cached.ClosedIssues
+				repo.OpenPullRequestCount = cached.OpenPullRequestCount
+				repo.ClosedPullRequestCount = cached.ClosedPullRequestCount
+				repo.MergedPullRequestCount = cached.MergedPullRequestCount
+			}
+
+			repo.SelfWrittenLOC = cached.SelfWrittenLOC
+			repo.ThirdPartyLOC = cached.ThirdPartyLOC
+			repo.UnresolvedDependencies = cached.UnresolvedDependencies
+		}
+
+		// Repositories with missing metrics are not stored, so the next search retries them. Neither are repositories
+		// computed for a selection of fields, which would be served to searches for all fields otherwise. Stored
+		// repositories are not stored again, so their entry still expires after the TTL of the store.
+		if rs.store != nil && cached == nil && !failed && len(fields) == 0 {
+			if err = rs.store.Put(repo, r.GetPushedAt().Time); err != nil {
+				slog.Warn("unable to store the repository: " + err.Error())
+			}
+		}
+
+		rs.completed <- repo.Select(fields)
+		rs.processed.Add(1)
+		if failed {
+			rs.failed.Add(1)
+		}
+
+		rs.emit(r.GetFullName(), model.StageCompleted, startTime)
+
 		slog.Debug(fmt.Sprintf("Completed Processing: %v | Processing Time: %.2f sec", r.GetFullName(), time.Since(startTime).Seconds()))
 
 		return
 	}
 }
 
+// clone is a method of the RepositoryService struct. It clones a repository with the token that has the most core
+// rate limit budget left. Repositories hosted elsewhere than the configured GitHub instance are cloned anonymously,
+// so the tokens are never sent to another host.
+func (rs *RepositoryService) clone(ctx context.Context, cloneURL string) (string, error) {
+	u, err := url.Parse(cloneURL)
+	if err != nil {
+		return "", util.Error(fmt.Errorf("invalid clone URL %v: %v", cloneURL, err))
+	}
+
+	if !strings.EqualFold(u.Hostname(), rs.tokens.host()) {
+		return util.Clone(ctx, "", cloneURL)
+	}
+
+	t, err := rs.tokens.next(ctx, resourceCore)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := t.credential(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return util.Clone(ctx, token, cloneURL)
+}
+
+// dependencyLOC is a method of the RepositoryService struct. It fetches the dependencies of a repository one at a time
+// with the resolver, and sums up the lines of code of the languages of the resolver. Dependencies that cannot be
+// fetched are returned as unresolved.
+func (rs *RepositoryService) dependencyLOC(ctx context.Context, repo string, resolver dependency.DependencyResolver, deps []dependency.Dependency, fail func(error)) (int, []string) {
+	var (
+		loc        int
+		unresolved []string
+	)
+
+	for _, dep := range deps {
+		if ctx.Err() != nil {
+			break
+		}
+
+		slog.Debug(fmt.Sprintf("Processing %v | Dependency: %v", repo, dep))
+
+		l, err := fetchLOC(ctx, resolver, dep)
+		if err != nil {
+			fail(err)
+			unresolved = append(unresolved, dep.String())
+			continue
+		}
+
+		loc += l
+	}
+
+	return loc, unresolved
+}
+
+// fetchLOC fetches a dependency into a temporary directory and counts its lines of code. A dependency without code in
+// the languages of the resolver, such as a package of type declarations or a wrapper of a native library, counts as
+// zero lines, as it was resolved all the same.
+func fetchLOC(ctx context.Context, resolver dependency.DependencyResolver, dep dependency.Dependency) (int, error) {
+	dir, err := os.MkdirTemp("", "dependency-")
+	if err != nil {
+		return 0, util.Error(fmt.Errorf("unable to create a temporary directory: %v", err))
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	if err = resolver.Fetch(ctx, dep, dir); err != nil {
+		return 0, err
+	}
+
+	loc, err := util.CalcLOC(dir, resolver.Languages()...)
+	if errors.Is(err, util.ErrLanguageNotFound) {
+		return 0, nil
+	}
+
+	return loc, err
+}
+
+// storedFields are the fields of a repository served from the store. Most are derived from the code of the repository,
+// which only changes with a push. The counts of issues and pull requests change without a push, but each costs a call
+// to the search API, whose rate limit is 30 requests a minute, so they are served as of the stored push too, until the
+// entry expires or the search is refreshed. The releases and contributors come from the core API and are read again.
+var storedFields = []string{
+	model.FieldOpenIssues,
+	model.FieldClosedIssues,
+	model.FieldOpenPullRequestCount,
+	model.FieldClosedPullRequestCount,
+	model.FieldMergedPullRequestCount,
+	model.FieldCommitCount,
+	model.FieldCommitCountMethod,
+	model.FieldSelfWrittenLOC,
+	model.FieldThirdPartyLOC,
+	model.FieldUnresolvedDependencies,
+}
+
+// metadataFields are the fields of a repository computed from its metadata, see repoMetadata.
+var metadataFields = []string{
+	model.FieldOpenIssues,
+	model.FieldClosedIssues,
+	model.FieldOpenPullRequestCount,
+	model.FieldClosedPullRequestCount,
+	model.FieldMergedPullRequestCount,
+	model.FieldCommitCount,
+	model.FieldCommitCountMethod,
+	model.FieldLatestRelease,
+	model.FieldTotalReleasesCount,
+}
+
+// repoMetadata holds the metrics of a repository read from the GitHub API, apart from the contributors.
+type repoMetadata struct {
+	openIssues         int
+	closedIssues       int
+	openPullRequests   int
+	closedPullRequests int
+	mergedPullRequests int
+	commitCount        int
+	commitErr          error
+	releases           int
+	latestRelease      time.Time
+}
+
+// restMetadata is a method of the RepositoryService struct. It reads the given fields of the metadata of a repository
+// with the REST API, one endpoint at a time. Errors other than failing to count the commits, which the caller may still
+// count from a clone, are passed to fail.
+func (rs *RepositoryService) restMetadata(ctx context.Context, name string, fields model.Fields, startTime time.Time, fail func(error)) *repoMetadata {
+	m := &repoMetadata{}
+
+	var err error
+
+	count := func(field string, qualifiers string, n *int) {
+		if !fields.Has(field) {
+			return
+		}
+
+		if *n, err = rs.repoSearchCount(ctx, name, qualifiers); err != nil {
+			fail(err)
+		}
+	}
+
+	if fields.Has(model.FieldOpenPullRequestCount, model.FieldClosedPullRequestCount, model.FieldMergedPullRequestCount) {
+		rs.emit(name, model.StagePulls, startTime)
+		count(model.FieldOpenPullRequestCount, "is:pr is:open", &m.openPullRequests)
+		count(model.FieldClosedPullRequestCount, "is:pr is:closed", &m.closedPullRequests)
+		count(model.FieldMergedPullRequestCount, "is:pr is:merged", &m.mergedPullRequests)
+	}
+
+	if fields.Has(model.FieldOpenIssues, model.FieldClosedIssues) {
+		rs.emit(name, model.StageIssues, startTime)
+		count(model.FieldOpenIssues, "is:issue is:open", &m.openIssues)
+		count(model.FieldClosedIssues, "is:issue is:closed", &m.closedIssues)
+	}
+
+	if fields.Has(model.FieldCommitCount, model.FieldCommitCountMethod) {
+		rs.emit(name, model.StageCommits, startTime)
+		m.commitCount, m.commitErr = rs.repoCommitCount(ctx, name)
+	}
+
+	if fields.Has(model.FieldTotalReleasesCount) {
+		rs.emit(name, model.StageReleases, startTime)
+		releases, err := rs.repoLatestRelease(ctx, name)
+		if err != nil {
+			fail(err)
+		}
+
+		m.releases = len(releases)
+	}
+
+	if fields.Has(model.FieldLatestRelease) {
+		rs.emit(name, model.StageLatestRelease, startTime)
+		latestRelease, err := rs.repositoryLatestRelease(ctx, name)
+		if err != nil {
+			fail(err)
+		}
+
+		m.latestRelease = latestRelease.GetPublishedAt().Time
+	}
+
+	return m
+}
+
 // repoContributors is a method of the RepositoryService struct. It retrieves detailed information about the contributors
 // of a GitHub repository based on the provided full name of the repository. It makes use of the List contributors API
 // endpoint (https://docs.github.com/en/rest/reference/repos#list-repository-contributors).
-func (rs *RepositoryService) repoContributors(name string) ([]*github.Contributor, error) {
+func (rs *RepositoryService) repoContributors(ctx context.Context, name string) ([]*github.Contributor, error) {
 	opt := &github.ListContributorsOptions{
 		ListOptions: github.ListOptions{
 			PerPage: 100,
@@ -220,14 +760,10 @@ func (rs *RepositoryService) repoContributors(name string) ([]*github.Contributo
 	var all []*github.Contributor
 
 	for {
-		contributors, resp, err := rs.Client.Repositories.ListContributors(context.Background(), owner, repo, opt)
+		contributors, resp, err := do(ctx, rs, resourceCore, func(c *github.Client) ([]*github.Contributor, *github.Response, error) {
+			return c.Repositories.ListContributors(ctx, owner, repo, opt)
+		})
 		if err != nil {
-			var rateLimitError *github.RateLimitError
-			if errors.As(err, &rateLimitError) {
-				waitDuration := time.Until(rateLimitError.Rate.Reset.Time)
-				time.Sleep(waitDuration)
-				continue
-			}
 			return nil, util.Error(err)
 		}
 
@@ -247,31 +783,25 @@ func (rs *RepositoryService) repoContributors(name string) ([]*github.Contributo
 // repositoryLatestRelease is a method of the RepositoryService struct. It retrieves detailed information about the latest
 // release of a GitHub repository based on the provided full name of the repository. It makes use of the Get latest
 // release API endpoint (https://docs.github.com/en/rest/reference/repos#get-the-latest-release).
-func (rs *RepositoryService) repositoryLatestRelease(name string) (*github.RepositoryRelease, error) {
+func (rs *RepositoryService) repositoryLatestRelease(ctx context.Context, name string) (*github.RepositoryRelease, error) {
 	owner, repo := strings.Split(name, "/")[0], strings.Split(name, "/")[1]
 
-	for {
-		latestRelease, resp, err := rs.Client.Repositories.GetLatestRelease(context.Background(), owner, repo)
-		if err != nil {
-			var rateLimitError *github.RateLimitError
-			if errors.As(err, &rateLimitError) {
-				waitDuration := time.Until(rateLimitError.Rate.Reset.Time)
-				time.Sleep(waitDuration)
-				continue
-			}
-			return nil, util.Error(err)
-		}
+	latestRelease, resp, err := do(ctx, rs, resourceCore, func(c *github.Client) (*github.RepositoryRelease, *github.Response, error) {
+		return c.Repositories.GetLatestRelease(ctx, owner, repo)
+	})
+	if err != nil {
+		return nil, util.Error(err)
+	}
 
-		slog.Debug(fmt.Sprintf("GET /repos/%s/%s/releases/latest | Response: %v | Rate Limit Left: %v", owner, repo, resp.Status, resp.Rate.Remaining))
+	slog.Debug(fmt.Sprintf("GET /repos/%s/%s/releases/latest | Response: %v | Rate Limit Left: %v", owner, repo, resp.Status, resp.Rate.Remaining))
 
-		return latestRelease, nil
-	}
+	return latestRelease, nil
 }
 
 // repoLatestRelease is a method of the RepositoryService struct. It retrieves detailed information about the releases
 // of a GitHub repository based on the provided full name of the repository. It makes use of the List releases API
 // endpoint (https://docs.github.com/en/rest/reference/repos#list-releases).
-func (rs *RepositoryService) repoLatestRelease(name string) ([]*github.RepositoryRelease, error) {
+func (rs *RepositoryService) repoLatestRelease(ctx context.Context, name string) ([]*github.RepositoryRelease, error) {
 	opt := &github.ListOptions{
 		Page:    1,
 		PerPage: 100,
@@ -282,14 +812,10 @@ func (rs *RepositoryService) repoLatestRelease(name string) ([]*github.Repositor
 	var all []*github.RepositoryRelease
 
 	for {
-		releases, resp, err := rs.Client.Repositories.ListReleases(context.Background(), owner, repo, opt)
+		releases, resp, err := do(ctx, rs, resourceCore, func(c *github.Client) ([]*github.RepositoryRelease, *github.Response, error) {
+			return c.Repositories.ListReleases(ctx, owner, repo, opt)
+		})
 		if err != nil {
-			var rateLimitError *github.RateLimitError
-			if errors.As(err, &rateLimitError) {
-				waitDuration := time.Until(rateLimitError.Rate.Reset.Time)
-				time.Sleep(waitDuration)
-				continue
-			}
 			return nil, util.Error(err)
 		}
 
@@ -306,203 +832,383 @@ func (rs *RepositoryService) repoLatestRelease(name string) ([]*github.Repositor
 	return all, nil
 }
 
-// repoIssues is a method of the RepositoryService struct. It retrieves detailed information about the issues
-// of a GitHub repository based on the provided full name of the repository. It makes use of the List issues API
-// endpoint (https://docs.github.com/en/rest/reference/issues#list-repository-issues).
-func (rs *RepositoryService) repoIssues(name string) ([]*github.Issue, error) {
-	opt := &github.IssueListByRepoOptions{
-		ListOptions: github.ListOptions{PerPage: 100, Page: 1},
-		State:       "all",
+// repoSearchCount is a method of the RepositoryService struct. It counts the issues and pull requests of a GitHub
+// repository matching the given qualifiers, e.g. "is:pr is:merged". It makes use of the Search issues and pull
+// requests API endpoint (https://docs.github.com/en/rest/search/search#search-issues-and-pull-requests) and only reads
+// the total_count of a single result, instead of downloading every issue.
+func (rs *RepositoryService) repoSearchCount(ctx context.Context, name string, qualifiers string) (int, error) {
+	opt := &github.SearchOptions{
+		ListOptions: github.ListOptions{PerPage: 1, Page: 1},
 	}
 
-	owner, repo := strings.Split(name, "/")[0], strings.Split(name, "/")[1]
+	query := fmt.Sprintf("repo:%s %s", name, qualifiers)
 
-	var all []*github.Issue
+	r, resp, err := do(ctx, rs, resourceSearch, func(c *github.Client) (*github.IssuesSearchResult, *github.Response, error) {
+		return c.Search.Issues(ctx, query, opt)
+	})
+	if err != nil {
+		return 0, util.Error(fmt.Errorf("unable to count %q of %s: %v", qualifiers, name, err))
+	}
 
-	for {
-		r, resp, err := rs.Client.Issues.ListByRepo(context.Background(), owner, repo, opt)
-		if err != nil {
-			var rateLimitError *github.RateLimitError
-			if errors.As(err, &rateLimitError) {
-				waitDuration := time.Until(rateLimitError.Rate.Reset.Time)
-				time.Sleep(waitDuration)
-				continue
-			}
-			return nil, util.Error(err)
-		}
+	slog.Debug(fmt.Sprintf("GET /search/issues?q=%s | Response: %v | Rate Limit Left: %v", query, resp.Status, resp.Rate.Remaining))
 
-		all = append(all, r...)
+	return r.GetTotal(), nil
+}
 
-		if resp.NextPage == 0 {
-			break
+// repoCommitCount is a method of the RepositoryService struct. It counts the commits on the default branch of a GitHub
+// repository based on the provided full name of the repository. It makes use of the List commits API endpoint
+// (https://docs.github.com/en/rest/reference/repos#list-commits) with a single commit per page, so the number of the
+// last page in the Link header is the number of commits. An empty repository has no commits.
+func (rs *RepositoryService) repoCommitCount(ctx context.Context, name string) (int, error) {
+	opt := &github.CommitsListOptions{
+		ListOptions: github.ListOptions{PerPage: 1, Page: 1},
+	}
+
+	owner, repo := strings.Split(name, "/")[0], strings.Split(name, "/")[1]
+
+	r, resp, err := do(ctx, rs, resourceCore, func(c *github.Client) ([]*github.RepositoryCommit, *github.Response, error) {
+		return c.Repositories.ListCommits(ctx, owner, repo, opt)
+	})
+	if err != nil {
+		// GitHub answers 409 Conflict for repositories without any commits.
+		var errorResponse *github.ErrorResponse
+		if errors.As(err, &errorResponse) && errorResponse.Response.StatusCode == http.StatusConflict {
+			return 0, nil
 		}
 
-		slog.Debug(fmt.Sprintf("GET /repos/%s/%s/issues | Response: %v | Rate Limit Left: %v", owner, repo, resp.Status, resp.Rate.Remaining))
+		return 0, util.Error(fmt.Errorf("unable to count the commits of %s: %v", name, err))
+	}
 
-		opt.Page = resp.NextPage
+	slog.Debug(fmt.Sprintf("GET /repos/%s/%s/commits | Response: %v | Rate Limit Left: %v", owner, repo, resp.Status, resp.Rate.Remaining))
+
+	// Without a last page, every commit fits on the first page.
+	if resp.LastPage == 0 {
+		return len(r), nil
 	}
 
-	return all, nil
+	return resp.LastPage, nil
 }
 
-// repoPulls is a method of the RepositoryService struct. It retrieves detailed information about the pull requests
-// of a GitHub repository based on the provided full name of the repository. It makes use of the List pull requests API
-// endpoint (https://docs.github.com/en/rest/reference/pulls#list-pull-requests).
-func (rs *RepositoryService) repoPulls(name string) ([]*github.PullRequest, error) {
-	opt := &github.PullRequestListOptions{
-		ListOptions: github.ListOptions{PerPage: 100, Page: 1},
-		State:       "all",
+// singleRepoSearch is a method of the RepositoryService struct. It retrieves detailed information about a GitHub repository
+// based on the provided full name of the repository. It makes use of the Get a Repository API endpoint
+// (https://docs.github.com/en/rest/reference/repos#get-a-repository).
+//
+// The method takes a string argument, fullName, which is the full name of the repository in the format "owner/singleRepoSearch".
+// It returns a pointer to a github.Repository struct representing the queried repository and an error if any occurs during the process.
+func (rs *RepositoryService) singleRepoSearch(ctx context.Context, name string) (*github.Repository, error) {
+	owner, repo := strings.Split(name, "/")[0], strings.Split(name, "/")[1]
+
+	r, resp, err := do(ctx, rs, resourceCore, func(c *github.Client) (*github.Repository, *github.Response, error) {
+		return c.Repositories.Get(ctx, owner, repo)
+	})
+	if err != nil {
+		return nil, util.Error(fmt.Errorf(": %v", err))
 	}
 
-	owner, repo := strings.Split(name, "/")[0], strings.Split(name, "/")[1]
+	slog.Debug(fmt.Sprintf("GET /repos/%s/%s | Response: %v | Rate Limit Left: %v", owner, repo, resp.Status, resp.Rate.Remaining))
 
-	var all []*github.PullRequest
+	return r, nil
+}
 
-	for {
-		r, resp, err := rs.Client.PullRequests.List(context.Background(), owner, repo, opt)
+// searchResultLimit is the maximum number of results GitHub's Search API returns for a single query, regardless of
+// its total_count.
+const searchResultLimit = 1000
+
+// multiRepoSearch is a method of the RepositoryService struct. It searches for GitHub repositories based on the provided
+// query parameters. It makes use of the Search repositories API endpoint
+// (https://docs.github.com/en/rest/reference/search#search-repositories).
+//
+// GitHub never returns more than 1000 results for a query, so when the query matches more than that, the creation date
+// range, and for a single day the star range, is bisected until every slice fits. The slices are merged and
+// de-duplicated by full name. GitHub's total_count of the whole query is recorded and available through TotalCount.
+func (rs *RepositoryService) multiRepoSearch(ctx context.Context) ([]*github.Repository, error) {
+	first, err := time.Parse("2006-01-02", rs.QueryParameters.FirstCreationDate)
+	if err != nil {
+		return nil, util.Error(fmt.Errorf("invalid first creation date: %v", err))
+	}
+
+	last, err := time.Parse("2006-01-02", rs.QueryParameters.LastCreationDate)
+	if err != nil {
+		return nil, util.Error(fmt.Errorf("invalid last creation date: %v", err))
+	}
+
+	minStars, err := strconv.Atoi(rs.QueryParameters.MinStars)
+	if err != nil {
+		return nil, util.Error(fmt.Errorf("invalid min stars: %v", err))
+	}
+
+	maxStars, err := strconv.Atoi(rs.QueryParameters.MaxStars)
+	if err != nil {
+		return nil, util.Error(fmt.Errorf("invalid max stars: %v", err))
+	}
+
+	seen := make(map[string]struct{})
+	all := make([]*github.Repository, 0)
+
+	// GitHub has no way to search several languages in a single query, so every language is searched on its own.
+	var total int
+
+	for _, language := range rs.QueryParameters.Language {
+		s := &searchSlice{
+			language: language,
+			first:    first,
+			last:     last,
+			minStars: minStars,
+			maxStars: maxStars,
+		}
+
+		n, err := rs.searchRange(ctx, s, seen, &all)
 		if err != nil {
-			var rateLimitError *github.RateLimitError
-			if errors.As(err, &rateLimitError) {
-				waitDuration := time.Until(rateLimitError.Rate.Reset.Time)
-				time.Sleep(waitDuration)
-				continue
-			}
-			return nil, util.Error(err)
+			return nil, err
 		}
 
-		all = append(all, r...)
+		total += n
+	}
 
-		if resp.NextPage == 0 {
-			break
-		}
+	rs.totalCount.Store(int64(total))
 
-		slog.Debug(fmt.Sprintf("GET /repos/%s/%s/pulls | Response: %v | Rate Limit Left: %v", owner, repo, resp.Status, resp.Rate.Remaining))
+	sortRepositories(all, rs.QueryParameters.Sort, rs.QueryParameters.Order)
 
-		opt.Page = resp.NextPage
+	rs.rank = make(map[string]int, len(all))
+	for i, r := range all {
+		rs.rank[r.GetFullName()] = i
 	}
 
 	return all, nil
 }
 
-// repoCommits is a method of the RepositoryService struct. It retrieves detailed information about the commits
-// of a GitHub repository based on the provided full name of the repository. It makes use of the List commits API
-// endpoint (https://docs.github.com/en/rest/reference/repos#list-commits).
-func (rs *RepositoryService) repoCommits(name string) ([]*github.RepositoryCommit, error) {
-	opt := &github.CommitsListOptions{
-		ListOptions: github.ListOptions{PerPage: 100, Page: 1},
+// sortRepositories orders the merged results of a search like GitHub orders a single search, with the full name as the
+// tie-breaker. The results of the searches for every language and every slice of the creation date and star range are
+// merged one after the other, so only their own order is kept when sorting by best match or by help wanted issues,
+// which the results carry nothing to compare by.
+func sortRepositories(repos []*github.Repository, sort string, order string) {
+	var key func(*github.Repository) int64
+
+	switch sort {
+	case "", model.SortStars:
+		key = func(r *github.Repository) int64 { return int64(r.GetStargazersCount()) }
+	case model.SortForks:
+		key = func(r *github.Repository) int64 { return int64(r.GetForksCount()) }
+	case model.SortUpdated:
+		key = func(r *github.Repository) int64 { return r.GetUpdatedAt().Unix() }
+	default:
+		return
 	}
 
-	owner, repo := strings.Split(name, "/")[0], strings.Split(name, "/")[1]
-
-	var result []*github.RepositoryCommit
+	slices.SortStableFunc(repos, func(a, b *github.Repository) int {
+		c := cmp.Compare(key(a), key(b))
+		if !strings.EqualFold(order, "asc") {
+			c = -c
+		}
 
-	for {
-		r, resp, err := rs.Client.Repositories.ListCommits(context.Background(), owner, repo, opt)
-		if err != nil {
-			var rateLimitError *github.RateLimitError
-			if errors.As(err, &rateLimitError) {
-				waitDuration := time.Until(rateLimitError.Rate.Reset.Time)
-				time.Sleep(waitDuration)
-				continue
-			}
-			return nil, util.Error(fmt.Errorf(": %v", err))
+		if c != 0 {
+			return c
 		}
 
-		result = r
+		return strings.Compare(a.GetFullName(), b.GetFullName())
+	})
+}
 
-		if resp.NextPage == 0 {
-			break
-		}
+// searchSlice is a part of the creation date and star range of a search for a language.
+type searchSlice struct {
+	language string
+	first    time.Time
+	last     time.Time
+	minStars int
+	maxStars int
+}
 
-		slog.Debug(fmt.Sprintf("GET /repos/%s/%s/commits | Response: %v | Rate Limit Left: %v", owner, repo, resp.Status, resp.Rate.Remaining))
+// query builds the GitHub search query of the slice, followed by the qualifiers shared by every slice of the search.
+func (s *searchSlice) query(qualifiers string) string {
+	stars := fmt.Sprintf("%d..%d", s.minStars, s.maxStars)
 
-		opt.Page = resp.NextPage
+	// Names like "C++" or "Jupyter Notebook" only match as a quoted qualifier.
+	language := s.language
+	if strings.ContainsFunc(language, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		language = strconv.Quote(language)
 	}
 
-	return result, nil
+	query := fmt.Sprintf("language:%s stars:%s created:%s..%s", language, stars, s.first.Format("2006-01-02"), s.last.Format("2006-01-02"))
+	if qualifiers != "" {
+		query += " " + qualifiers
+	}
+
+	return query
 }
 
-// singleRepoSearch is a method of the RepositoryService struct. It retrieves detailed information about a GitHub repository
-// based on the provided full name of the repository. It makes use of the Get a Repository API endpoint
-// (https://docs.github.com/en/rest/reference/repos#get-a-repository).
-//
-// The method takes a string argument, fullName, which is the full name of the repository in the format "owner/singleRepoSearch".
-// It returns a pointer to a github.Repository struct representing the queried repository and an error if any occurs during the process.
-//
-// The method handles GitHub's rate limit by retrying the API call after a wait time if a rate limit error is encountered.
-// The number of retries and the wait time are defined in the RepositoryService struct.
-func (rs *RepositoryService) singleRepoSearch(name string) (*github.Repository, error) {
-	opt := &github.SearchOptions{
-		ListOptions: github.ListOptions{PerPage: 100, Page: 1},
-		Order:       rs.QueryParameters.Order,
+// qualifiers translates the optional search parameters into GitHub search syntax. The parameters have been validated
+// by QueryParameters.Validate, so they are used verbatim.
+func qualifiers(q *model.QueryParameters) string {
+	var terms []string
+
+	if q.Q != "" {
+		terms = append(terms, strings.Fields(q.Q)...)
 	}
 
-	owner, repo := strings.Split(name, "/")[0], strings.Split(name, "/")[1]
+	for _, topic := range q.Topics {
+		terms = append(terms, "topic:"+topic)
+	}
 
-	var result *github.Repository
+	if q.License != "" {
+		terms = append(terms, "license:"+q.License)
+	}
 
-	for {
-		r, resp, err := rs.Client.Repositories.Get(context.Background(), owner, repo)
-		if err != nil {
-			var rateLimitError *github.RateLimitError
-			if errors.As(err, &rateLimitError) {
-				waitDuration := time.Until(rateLimitError.Rate.Reset.Time)
-				time.Sleep(waitDuration)
-				continue
-			}
-			return nil, util.Error(fmt.Errorf(": %v", err))
-		}
+	if r := rangeQualifier(q.MinForks, q.MaxForks); r != "" {
+		terms = append(terms, "forks:"+r)
+	}
 
-		result = r
+	if r := rangeQualifier(q.PushedAfter, q.PushedBefore); r != "" {
+		terms = append(terms, "pushed:"+r)
+	}
 
-		if resp.NextPage == 0 {
-			break
-		}
+	if archived, err := strconv.ParseBool(q.Archived); err == nil {
+		terms = append(terms, "archived:"+strconv.FormatBool(archived))
+	}
 
-		slog.Debug(fmt.Sprintf("GET /repos/%s/%s | Response: %v | Rate Limit Left: %v", owner, repo, resp.Status, resp.Rate.Remaining))
+	// GitHub leaves forks out of the results unless asked for, so only a search including forks needs a qualifier.
+	if q.IsFork == model.ForkOnly {
+		terms = append(terms, "fork:only")
+	} else if fork, err := strconv.ParseBool(q.IsFork); err == nil && fork {
+		terms = append(terms, "fork:true")
+	}
 
-		opt.Page = resp.NextPage
+	if q.Org != "" {
+		terms = append(terms, "org:"+q.Org)
 	}
 
-	return result, nil
+	if q.User != "" {
+		terms = append(terms, "user:"+q.User)
+	}
+
+	if q.Size != "" {
+		terms = append(terms, "size:"+q.Size)
+	}
+
+	return strings.Join(terms, " ")
 }
 
-// multiRepoSearch is a method of the RepositoryService struct. It searches for GitHub repositories based on the provided
-// query parameters. It makes use of the Search repositories API endpoint
-// (https://docs.github.com/en/rest/reference/search#search-repositories).
-func (rs *RepositoryService) multiRepoSearch() ([]*github.Repository, error) {
+// rangeQualifier returns the range syntax of a qualifier with an optional lower and upper bound.
+func rangeQualifier(lower, upper string) string {
+	switch {
+	case lower != "" && upper != "":
+		return lower + ".." + upper
+	case lower != "":
+		return ">=" + lower
+	case upper != "":
+		return "<=" + upper
+	default:
+		return ""
+	}
+}
+
+// split bisects the slice, by creation date if it spans more than one day and by stars otherwise. It returns false if
+// the slice cannot be split any further.
+func (s *searchSlice) split() (*searchSlice, *searchSlice, bool) {
+	if days := int(s.last.Sub(s.first).Hours() / 24); days > 0 {
+		mid := s.first.AddDate(0, 0, days/2)
+
+		left, right := *s, *s
+		left.last = mid
+		right.first = mid.AddDate(0, 0, 1)
+
+		return &left, &right, true
+	}
+
+	if s.minStars >= s.maxStars {
+		return nil, nil, false
+	}
+
+	left, right := *s, *s
+	left.maxStars = s.minStars + (s.maxStars-s.minStars)/2
+	right.minStars = left.maxStars + 1
+
+	return &left, &right, true
+}
+
+// searchRange is a method of the RepositoryService struct. It collects the repositories of a search slice into all,
+// skipping the ones already seen, and returns GitHub's total_count of the slice. Slices matching more repositories
+// than GitHub returns are split and searched recursively.
+func (rs *RepositoryService) searchRange(ctx context.Context, s *searchSlice, seen map[string]struct{}, all *[]*github.Repository) (int, error) {
+	query := s.query(qualifiers(rs.QueryParameters))
+
 	opt := &github.SearchOptions{
 		ListOptions: github.ListOptions{PerPage: 100, Page: 1},
 		Order:       rs.QueryParameters.Order,
-		Sort:        "stars",
+		Sort:        searchSort(rs.QueryParameters.Sort),
 	}
 
-	query := fmt.Sprintf("language:%s stars:%s..%s created:%s..%s", rs.QueryParameters.Language, rs.QueryParameters.MinStars, rs.QueryParameters.MaxStars, rs.QueryParameters.FirstCreationDate, rs.QueryParameters.LastCreationDate)
+	r, resp, err := rs.searchPage(ctx, query, opt)
+	if err != nil {
+		return 0, err
+	}
 
-	all := make([]*github.Repository, 0)
+	total := r.GetTotal()
+
+	if total > searchResultLimit {
+		if left, right, ok := s.split(); ok {
+			slog.Debug(fmt.Sprintf("Splitting Search: %v | Total Count: %v", query, total))
+
+			if _, err = rs.searchRange(ctx, left, seen, all); err != nil {
+				return 0, err
+			}
+
+			if _, err = rs.searchRange(ctx, right, seen, all); err != nil {
+				return 0, err
+			}
+
+			return total, nil
+		}
+
+		slog.Warn(fmt.Sprintf("unable to split the search any further, only %v of %v results are available: %v", searchResultLimit, total, query))
+	}
 
 	for {
-		r, resp, err := rs.Client.Search.Repositories(context.Background(), query, opt)
-		if err != nil {
-			var rateLimitError *github.RateLimitError
-			if errors.As(err, &rateLimitError) {
-				waitDuration := time.Until(rateLimitError.Rate.Reset.Time)
-				time.Sleep(waitDuration)
+		for _, repo := range r.Repositories {
+			if _, ok := seen[repo.GetFullName()]; ok {
 				continue
 			}
-			return nil, util.Error(fmt.Errorf(": %v", err))
-		}
 
-		all = append(all, r.Repositories...)
+			seen[repo.GetFullName()] = struct{}{}
+			*all = append(*all, repo)
+		}
 
 		if resp.NextPage == 0 {
 			break
 		}
 
-		slog.Debug(fmt.Sprintf("GET /search/repositories | Response: %v | Rate Limit Left: %v", resp.Status, resp.Rate.Remaining))
-
 		opt.Page = resp.NextPage
+
+		if r, resp, err = rs.searchPage(ctx, query, opt); err != nil {
+			return 0, err
+		}
 	}
 
-	return all, nil
+	return total, nil
+}
+
+// searchSort returns the sort of the Search API for the sort parameter. Best match is GitHub's default sort, used when
+// no sort is sent.
+func searchSort(sort string) string {
+	switch sort {
+	case "":
+		return model.SortStars
+	case model.SortBestMatch:
+		return ""
+	default:
+		return sort
+	}
+}
+
+// searchPage is a method of the RepositoryService struct. It fetches a single page of search results.
+func (rs *RepositoryService) searchPage(ctx context.Context, query string, opt *github.SearchOptions) (*github.RepositoriesSearchResult, *github.Response, error) {
+	r, resp, err := do(ctx, rs, resourceSearch, func(c *github.Client) (*github.RepositoriesSearchResult, *github.Response, error) {
+		return c.Search.Repositories(ctx, query, opt)
+	})
+	if err != nil {
+		return nil, nil, util.Error(fmt.Errorf(": %v", err))
+	}
+
+	slog.Debug(fmt.Sprintf("GET /search/repositories | Response: %v | Rate Limit Left: %v", resp.Status, resp.Rate.Remaining))
+
+	return r, resp, nil
 }
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/haapjari/repository-search-api/internal/pkg/model"
+)
+
+func date(t *testing.T, value string) time.Time {
+	t.Helper()
+
+	d, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func TestSearchSliceSplit(t *testing.T) {
+	slice := func(first, last string, minStars, maxStars int) *searchSlice {
+		return &searchSlice{language: "Go", first: date(t, first), last: date(t, last), minStars: minStars, maxStars: maxStars}
+	}
+
+	tests := []struct {
+		name      string
+		slice     *searchSlice
+		wantLeft  *searchSlice
+		wantRight *searchSlice
+		wantOK    bool
+	}{
+		{
+			name:      "date range",
+			slice:     slice("2020-01-01", "2020-01-31", 10, 100),
+			wantLeft:  slice("2020-01-01", "2020-01-16", 10, 100),
+			wantRight: slice("2020-01-17", "2020-01-31", 10, 100),
+			wantOK:    true,
+		},
+		{
+			name:      "two days",
+			slice:     slice("2020-01-01", "2020-01-02", 10, 100),
+			wantLeft:  slice("2020-01-01", "2020-01-01", 10, 100),
+			wantRight: slice("2020-01-02", "2020-01-02", 10, 100),
+			wantOK:    true,
+		},
+		{
+			name:      "single day splits the stars",
+			slice:     slice("2020-01-01", "2020-01-01", 10, 100),
+			wantLeft:  slice("2020-01-01", "2020-01-01", 10, 55),
+			wantRight: slice("2020-01-01", "2020-01-01", 56, 100),
+			wantOK:    true,
+		},
+		{
+			name:      "two stars",
+			slice:     slice("2020-01-01", "2020-01-01", 0, 1),
+			wantLeft:  slice("2020-01-01", "2020-01-01", 0, 0),
+			wantRight: slice("2020-01-01", "2020-01-01", 1, 1),
+			wantOK:    true,
+		},
+		{
+			name:   "single day and star",
+			slice:  slice("2020-01-01", "2020-01-01", 42, 42),
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, right, ok := tt.slice.split()
+			if ok != tt.wantOK {
+				t.Fatalf("split() ok = %v, want %v", ok, tt.wantOK)
+			}
+
+			if !reflect.DeepEqual(left, tt.wantLeft) {
+				t.Errorf("split() left = %+v, want %+v", left, tt.wantLeft)
+			}
+
+			if !reflect.DeepEqual(right, tt.wantRight) {
+				t.Errorf("split() right = %+v, want %+v", right, tt.wantRight)
+			}
+		})
+	}
+}
+
+func TestSearchSliceQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		language   string
+		minStars   int
+		maxStars   int
+		qualifiers string
+		want       string
+	}{
+		{
+			name:     "plain",
+			language: "Go", minStars: 10, maxStars: 100,
+			want: "language:Go stars:10..100 created:2020-01-01..2020-01-31",
+		},
+		{
+			name:     "zero stars",
+			language: "Go", minStars: 0, maxStars: 0,
+			want: "language:Go stars:0..0 created:2020-01-01..2020-01-31",
+		},
+		{
+			name:     "quoted language",
+			language: "C++", minStars: 1, maxStars: 2,
+			want: `language:"C++" stars:1..2 created:2020-01-01..2020-01-31`,
+		},
+		{
+			name:     "language with a space",
+			language: "Jupyter Notebook", minStars: 1, maxStars: 2,
+			want: `language:"Jupyter Notebook" stars:1..2 created:2020-01-01..2020-01-31`,
+		},
+		{
+			name:     "qualifiers",
+			language: "Go", minStars: 1, maxStars: 2, qualifiers: "topic:cli archived:false",
+			want: "language:Go stars:1..2 created:2020-01-01..2020-01-31 topic:cli archived:false",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &searchSlice{
+				language: tt.language,
+				first:    date(t, "2020-01-01"),
+				last:     date(t, "2020-01-31"),
+				minStars: tt.minStars,
+				maxStars: tt.maxStars,
+			}
+
+			if got := s.query(tt.qualifiers); got != tt.want {
+				t.Errorf("query() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQualifiers(t *testing.T) {
+	tests := []struct {
+		name  string
+		query model.QueryParameters
+		want  string
+	}{
+		{name: "none", want: ""},
+		{
+			name: "every qualifier",
+			query: model.QueryParameters{
+				Q:            "http  router",
+				Topics:       []string{"cli", "go"},
+				License:      "mit",
+				MinForks:     "5",
+				MaxForks:     "50",
+				PushedAfter:  "2024-01-01",
+				PushedBefore: "2024-06-30",
+				Archived:     "false",
+				Org:          "golang",
+				User:         "octocat",
+				Size:         ">=100",
+			},
+			want: "http router topic:cli topic:go license:mit forks:5..50 pushed:2024-01-01..2024-06-30 archived:false org:golang user:octocat size:>=100",
+		},
+		{name: "lower bounds", query: model.QueryParameters{MinForks: "5", PushedAfter: "2024-01-01"}, want: "forks:>=5 pushed:>=2024-01-01"},
+		{name: "upper bounds", query: model.QueryParameters{MaxForks: "50", PushedBefore: "2024-06-30"}, want: "forks:<=50 pushed:<=2024-06-30"},
+		{name: "archived", query: model.QueryParameters{Archived: "1"}, want: "archived:true"},
+		{name: "forks included", query: model.QueryParameters{IsFork: "true"}, want: "fork:true"},
+		{name: "forks only", query: model.QueryParameters{IsFork: model.ForkOnly}, want: "fork:only"},
+		{name: "forks excluded", query: model.QueryParameters{IsFork: "false"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := qualifiers(&tt.query); got != tt.want {
+				t.Errorf("qualifiers() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
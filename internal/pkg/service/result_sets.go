@@ -0,0 +1,90 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/haapjari/repository-search-api/internal/pkg/model"
+)
+
+var (
+	// ErrResultSetNotFound is returned when a result set does not exist or has expired.
+	ErrResultSetNotFound = errors.New("result set not found")
+	// ErrResultSetForbidden is returned when a result set is requested by someone else than the owner it was kept for.
+	ErrResultSetForbidden = errors.New("result set belongs to other credentials")
+)
+
+// ResultSets keeps the responses of finished searches for a while, so that clients can page through them without
+// repeating the search. Every response belongs to an owner, e.g. a hash of the credentials the search was made with,
+// and is only returned to the same owner.
+type ResultSets struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	sets map[string]*resultSet
+}
+
+type resultSet struct {
+	response *model.RepositoryResponse
+	owner    string
+	expires  time.Time
+}
+
+func NewResultSets(ttl time.Duration) *ResultSets {
+	return &ResultSets{
+		ttl:  ttl,
+		sets: make(map[string]*resultSet),
+	}
+}
+
+// Put is a method of the ResultSets struct. It keeps a response of the given owner and returns the ID to look it up
+// with.
+func (rs *ResultSets) Put(response *model.RepositoryResponse, owner string) (string, error) {
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.expire(time.Now())
+
+	rs.sets[id] = &resultSet{
+		response: response,
+		owner:    owner,
+		expires:  time.Now().Add(rs.ttl),
+	}
+
+	return id, nil
+}
+
+// Get is a method of the ResultSets struct. It returns the response with the given ID, if it belongs to the given
+// owner.
+func (rs *ResultSets) Get(id string, owner string) (*model.RepositoryResponse, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.expire(time.Now())
+
+	set, ok := rs.sets[id]
+	if !ok {
+		return nil, ErrResultSetNotFound
+	}
+
+	if subtle.ConstantTimeCompare([]byte(set.owner), []byte(owner)) != 1 {
+		return nil, ErrResultSetForbidden
+	}
+
+	return set.response, nil
+}
+
+// expire is a method of the ResultSets struct. It removes the expired responses. The caller must hold the lock.
+func (rs *ResultSets) expire(now time.Time) {
+	for id, set := range rs.sets {
+		if now.After(set.expires) {
+			delete(rs.sets, id)
+		}
+	}
+}
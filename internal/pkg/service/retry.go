@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+const (
+	// GitHub asks to wait at least a minute after hitting a secondary rate limit that came without a Retry-After header.
+	secondaryRateLimitWait = time.Minute
+
+	backoffBase = time.Second
+	backoffMax  = time.Minute
+)
+
+// retryDelay returns how long to wait before repeating a failed call, or false if the failure is not transient. A
+// secondary rate limit waits for as long as GitHub asks to, a server error backs off exponentially with jitter based on
+// the number of previous attempts.
+func retryDelay(err error, resp *http.Response, attempt int) (time.Duration, bool) {
+	var abuseRateLimitError *github.AbuseRateLimitError
+	if errors.As(err, &abuseRateLimitError) {
+		if abuseRateLimitError.RetryAfter != nil {
+			return *abuseRateLimitError.RetryAfter, true
+		}
+
+		return secondaryRateLimitWait, true
+	}
+
+	if resp == nil {
+		return 0, false
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		// The GraphQL API reports secondary rate limits only with the status code and the Retry-After header.
+		if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+
+		return 0, false
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return backoff(attempt), true
+	default:
+		return 0, false
+	}
+}
+
+// backoff returns a random delay between half and all of the exponential backoff of the attempt, so concurrent
+// workers failing at the same time do not retry at the same time as well.
+func backoff(attempt int) time.Duration {
+	d := backoffMax
+	if attempt < 16 {
+		d = min(backoffBase<<attempt, backoffMax)
+	}
+
+	return d/2 + rand.N(d/2+1)
+}
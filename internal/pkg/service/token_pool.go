@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+	"github.com/haapjari/repository-search-api/internal/pkg/githubapp"
+	"github.com/haapjari/repository-search-api/internal/pkg/model"
+)
+
+// GitHub tracks a separate rate limit for every resource, so the budget of a token is tracked per resource too.
+const (
+	resourceCore    = "core"
+	resourceSearch  = "search"
+	resourceGraphQL = "graphql"
+)
+
+// ErrNoTokens is returned when a call is made through a pool without any tokens.
+var ErrNoTokens = errors.New("no GitHub tokens available")
+
+type rateState struct {
+	remaining int
+	limit     int
+	reset     time.Time
+}
+
+// pooledToken is a GitHub token with its client and the rate limits GitHub last reported for it. The token is either a
+// static personal access token, or the refreshed access token of a GitHub App installation.
+type pooledToken struct {
+	token        string
+	installation *githubapp.Installation
+	client       *github.Client
+
+	mu    sync.Mutex
+	rates map[string]rateState
+}
+
+// budget returns the remaining requests of the token for a resource, and when the budget resets. A token that has not
+// been used yet, or whose rate limit has reset since, has an unknown and therefore maximal budget.
+func (t *pooledToken) budget(resource string, now time.Time) (int, time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.rates[resource]
+	if !ok || now.After(r.reset) {
+		return math.MaxInt, time.Time{}
+	}
+
+	return r.remaining, r.reset
+}
+
+// credential returns the current access token, to authenticate git operations with.
+func (t *pooledToken) credential(ctx context.Context) (string, error) {
+	if t.installation != nil {
+		return t.installation.Token(ctx)
+	}
+
+	return t.token, nil
+}
+
+// name returns a description of the token that is safe to expose.
+func (t *pooledToken) name() string {
+	if t.installation != nil {
+		return fmt.Sprintf("installation:%d", t.installation.ID())
+	}
+
+	return mask(t.token)
+}
+
+func (t *pooledToken) setRate(resource string, rate github.Rate) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rates[resource] = rateState{
+		remaining: rate.Remaining,
+		limit:     rate.Limit,
+		reset:     rate.Reset.Time,
+	}
+}
+
+// TokenPool routes every GitHub call to the token with the most rate limit budget left for the resource of the call,
+// and only waits for a reset once every token is exhausted.
+type TokenPool struct {
+	tokens    []*pooledToken
+	transport http.RoundTripper
+	baseURL   *url.URL
+	uploadURL *url.URL
+}
+
+// NewTokenPool creates a pool of the given tokens, whose clients send their requests through transport. A nil
+// transport uses http.DefaultTransport. Empty and duplicate tokens are ignored. The clients call api.github.com unless
+// baseURL points to a GitHub Enterprise Server, whose upload URL defaults to the base URL.
+func NewTokenPool(transport http.RoundTripper, baseURL, uploadURL string, tokens ...string) (*TokenPool, error) {
+	client := github.NewClient(nil)
+
+	if baseURL != "" {
+		if uploadURL == "" {
+			uploadURL = baseURL
+		}
+
+		var err error
+		if client, err = client.WithEnterpriseURLs(baseURL, uploadURL); err != nil {
+			return nil, fmt.Errorf("invalid GitHub Enterprise URL: %v", err)
+		}
+	}
+
+	pool := &TokenPool{
+		transport: transport,
+		baseURL:   client.BaseURL,
+		uploadURL: client.UploadURL,
+	}
+
+	return pool.With(tokens...), nil
+}
+
+// With is a method of the TokenPool struct. It returns a pool with the tokens of p and the given tokens. The tokens
+// already in p share their rate limit state with p, so budgets used through either pool are tracked in both.
+func (p *TokenPool) With(tokens ...string) *TokenPool {
+	pool := &TokenPool{
+		tokens:    append([]*pooledToken(nil), p.tokens...),
+		transport: p.transport,
+		baseURL:   p.baseURL,
+		uploadURL: p.uploadURL,
+	}
+
+	for _, token := range tokens {
+		if token == "" || pool.contains(token) {
+			continue
+		}
+
+		client := github.NewClient(&http.Client{Transport: p.transport}).WithAuthToken(token)
+		client.BaseURL, client.UploadURL = p.baseURL, p.uploadURL
+
+		pool.tokens = append(pool.tokens, &pooledToken{
+			token:  token,
+			client: client,
+			rates:  make(map[string]rateState),
+		})
+	}
+
+	return pool
+}
+
+// WithApp is a method of the TokenPool struct. It returns a pool with the tokens of p and the given installations of a
+// GitHub App, whose access tokens are minted and refreshed automatically. Every installation has a rate limit of its
+// own.
+func (p *TokenPool) WithApp(app *githubapp.App, installations ...int64) *TokenPool {
+	pool := p.With()
+
+	for _, id := range installations {
+		installation := app.Installation(id)
+
+		client := github.NewClient(&http.Client{Transport: installation.Transport(p.transport)})
+		client.BaseURL, client.UploadURL = p.baseURL, p.uploadURL
+
+		pool.tokens = append(pool.tokens, &pooledToken{
+			installation: installation,
+			client:       client,
+			rates:        make(map[string]rateState),
+		})
+	}
+
+	return pool
+}
+
+// Len is a method of the TokenPool struct. It returns the number of tokens in the pool.
+func (p *TokenPool) Len() int {
+	return len(p.tokens)
+}
+
+// State is a method of the TokenPool struct. It returns the last known rate limits of every token, with the tokens
+// masked.
+func (p *TokenPool) State() []model.TokenState {
+	state := make([]model.TokenState, 0, len(p.tokens))
+
+	for _, t := range p.tokens {
+		t.mu.Lock()
+
+		resources := make(map[string]model.RateLimit, len(t.rates))
+		for resource, r := range t.rates {
+			resources[resource] = model.RateLimit{
+				Remaining: r.remaining,
+				Limit:     r.limit,
+				Reset:     r.reset.UTC(),
+			}
+		}
+
+		t.mu.Unlock()
+
+		state = append(state, model.TokenState{
+			Token:     t.name(),
+			Resources: resources,
+		})
+	}
+
+	sort.SliceStable(state, func(i, j int) bool {
+		return state[i].Token < state[j].Token
+	})
+
+	return state
+}
+
+func (p *TokenPool) contains(token string) bool {
+	for _, t := range p.tokens {
+		if t.installation == nil && t.token == token {
+			return true
+		}
+	}
+
+	return false
+}
+
+// host is a method of the TokenPool struct. It returns the host serving the git repositories of the GitHub instance the
+// pool calls, which is the API host without its "api." prefix on github.com, and the same host on GitHub Enterprise
+// Server.
+func (p *TokenPool) host() string {
+	return strings.TrimPrefix(p.baseURL.Hostname(), "api.")
+}
+
+// next is a method of the TokenPool struct. It returns the token with the most budget left for the resource, waiting
+// for the earliest reset if every token is exhausted.
+func (p *TokenPool) next(ctx context.Context, resource string) (*pooledToken, error) {
+	if len(p.tokens) == 0 {
+		return nil, ErrNoTokens
+	}
+
+	for {
+		now := time.Now()
+
+		var (
+			best     *pooledToken
+			most     = 0
+			earliest time.Time
+		)
+
+		for _, t := range p.tokens {
+			remaining, reset := t.budget(resource, now)
+
+			if remaining > most {
+				best, most = t, remaining
+			}
+
+			if remaining == 0 && (earliest.IsZero() || reset.Before(earliest)) {
+				earliest = reset
+			}
+		}
+
+		if best != nil {
+			return best, nil
+		}
+
+		if err := sleep(ctx, time.Until(earliest)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// do calls fn with the client of the token with the most budget left for the resource. When GitHub answers that the
+// rate limit of the token is exceeded, the call is repeated with the next best token. Secondary rate limits and server
+// errors are retried at most retryCount times, see retryDelay.
+func do[T any](ctx context.Context, rs *RepositoryService, resource string, fn func(*github.Client) (T, *github.Response, error)) (T, *github.Response, error) {
+	var zero T
+
+	for attempt := 0; ; {
+		t, err := rs.tokens.next(ctx, resource)
+		if err != nil {
+			return zero, nil, err
+		}
+
+		v, resp, err := fn(t.client)
+
+		if resp != nil && resp.Rate.Limit > 0 {
+			t.setRate(resource, resp.Rate)
+			rs.observe(resp)
+		}
+
+		var rateLimitError *github.RateLimitError
+		if errors.As(err, &rateLimitError) {
+			rate := rateLimitError.Rate
+			rate.Remaining = 0
+
+			t.setRate(resource, rate)
+			continue
+		}
+
+		if err == nil || attempt >= rs.retryCount {
+			return v, resp, err
+		}
+
+		var httpResp *http.Response
+		if resp != nil {
+			httpResp = resp.Response
+		}
+
+		delay, ok := retryDelay(err, httpResp, attempt)
+		if !ok {
+			return v, resp, err
+		}
+
+		attempt++
+		slog.Warn(fmt.Sprintf("retrying a GitHub call | Attempt: %v/%v | Delay: %v | Error: %v", attempt, rs.retryCount, delay, err))
+
+		if err = sleep(ctx, delay); err != nil {
+			return zero, resp, err
+		}
+	}
+}
+
+// mask hides all but the last four characters of a token.
+func mask(token string) string {
+	if len(token) <= 4 {
+		return "****"
+	}
+
+	return "****" + token[len(token)-4:]
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/haapjari/repository-search-api/internal/pkg/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+var repositoriesBucket = []byte("repositories")
+
+// Store is an on-disk cache of computed repositories. Entries are keyed by the full name of the repository and the
+// time it was last pushed to, so a repository that has changed since it was stored is never served from the cache.
+type Store struct {
+	db  *bolt.DB
+	ttl time.Duration
+}
+
+type entry struct {
+	Repository *model.Repository `json:"repository"`
+	StoredAt   time.Time         `json:"stored_at"`
+}
+
+// Open opens, or creates, the store at the given path. Entries older than ttl are considered expired, a ttl of 0 keeps
+// them forever.
+func Open(path string, ttl time.Duration) (*Store, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("unable to create the store directory: %v", err)
+		}
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the store: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, bucketErr := tx.CreateBucketIfNotExists(repositoriesBucket)
+		return bucketErr
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("unable to initialize the store: %v", err)
+	}
+
+	return &Store{
+		db:  db,
+		ttl: ttl,
+	}, nil
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Get returns the stored repository with the given full name and push time, or false if there is no such entry or it
+// has expired.
+func (s *Store) Get(fullName string, pushedAt time.Time) (*model.Repository, bool) {
+	var e entry
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(repositoriesBucket).Get(key(fullName, pushedAt))
+		if v == nil {
+			return os.ErrNotExist
+		}
+
+		return json.Unmarshal(v, &e)
+	})
+	if err != nil {
+		return nil, false
+	}
+
+	if s.ttl > 0 && time.Since(e.StoredAt) > s.ttl {
+		return nil, false
+	}
+
+	return e.Repository, true
+}
+
+// Put stores a repository for the given push time, replacing any entries stored for earlier pushes.
+func (s *Store) Put(repo *model.Repository, pushedAt time.Time) error {
+	v, err := json.Marshal(&entry{
+		Repository: repo,
+		StoredAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(repositoriesBucket)
+		p := prefix(repo.FullName)
+
+		// Deleting while iterating would move the cursor, so collect the stale keys first.
+		var stale [][]byte
+
+		c := b.Cursor()
+		for k, _ := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, _ = c.Next() {
+			stale = append(stale, append([]byte(nil), k...))
+		}
+
+		for _, k := range stale {
+			if err = b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return b.Put(key(repo.FullName, pushedAt), v)
+	})
+}
+
+func prefix(fullName string) []byte {
+	return []byte(fullName + "\x00")
+}
+
+func key(fullName string, pushedAt time.Time) []byte {
+	return append(prefix(fullName), pushedAt.UTC().Format(time.RFC3339)...)
+}
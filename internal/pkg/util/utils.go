@@ -1,17 +1,19 @@
 package util
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
-	"unicode"
+	"sync"
 
+	"github.com/go-enry/go-enry/v2/data"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/hhatto/gocloc"
-	"golang.org/x/mod/modfile"
 )
 
 func FindFile(path, fileName string) (string, error) {
@@ -38,33 +40,71 @@ func FindFile(path, fileName string) (string, error) {
 	return foundPath, nil
 }
 
-func OnlyLetters(s string) bool {
-	for _, r := range s {
-		if !unicode.IsLetter(r) {
-			return false
-		}
+// definedLanguages are the languages gocloc can count the lines of code of, keyed by their lowercase name.
+var definedLanguages = sync.OnceValue(func() map[string]string {
+	names := make(map[string]string)
+
+	for name := range gocloc.NewDefinedLanguages().Langs {
+		names[strings.ToLower(name)] = name
 	}
-	return true
+
+	return names
+})
+
+// clocLanguages maps the names GitHub's linguist gives languages to the names gocloc counts them by, where the two
+// differ, keyed by the lowercase linguist name.
+var clocLanguages = map[string][]string{
+	"shell":             {"BASH", "Bourne Shell", "Zsh"},
+	"vim script":        {"VimL"},
+	"batchfile":         {"Batch"},
+	"java server pages": {"JSP"},
+	"common lisp":       {"LISP"},
+	"protocol buffer":   {"Protocol Buffers"},
+	"visual basic .net": {"Visual Basic"},
+}
+
+// KnownLanguage returns the canonical name of a programming language, e.g. "C++" for "c++", or false if gocloc does not
+// know the language, and cannot count its lines of code. Languages gocloc knows by another name, e.g. "Shell", are
+// returned as given.
+func KnownLanguage(name string) (string, bool) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if _, ok := clocLanguages[key]; ok {
+		return strings.TrimSpace(name), true
+	}
+
+	canonical, ok := definedLanguages()[key]
+	return canonical, ok
+}
+
+// GitHubLanguage returns the name GitHub's linguist gives a language, or one of its aliases, e.g. "Shell" for "bash",
+// or false if GitHub does not know the language.
+func GitHubLanguage(name string) (string, bool) {
+	return data.LanguageByAlias(strings.TrimSpace(name))
 }
 
-func Clone(token string, url string) (string, error) {
+// Clone clones the repository at url into a new temporary directory and returns its path. The directory is removed
+// again if the clone fails or ctx is cancelled.
+func Clone(ctx context.Context, token string, url string) (string, error) {
 	dir, err := os.MkdirTemp("", "clone-")
 	if err != nil {
 		return "", Error(fmt.Errorf("unable to create a temporary directory: %v", err))
 	}
 
-	auth := &http.BasicAuth{
-		Username: "x-access-token",
-		Password: strings.Split(token, " ")[1],
+	// No progress is written, as the output of concurrent clones would interleave with the log.
+	opts := &git.CloneOptions{
+		URL: url,
 	}
 
-	repo, err := git.PlainClone(dir, false,
-		&git.CloneOptions{
-			URL:      url,
-			Progress: os.Stdout,
-			Auth:     auth,
-		})
+	if token != "" {
+		opts.Auth = &http.BasicAuth{
+			Username: "x-access-token",
+			Password: token,
+		}
+	}
+
+	repo, err := git.PlainCloneContext(ctx, dir, false, opts)
 	if err != nil {
+		_ = os.RemoveAll(dir)
 		return "", Error(fmt.Errorf("unable to clone the repository: %v", err))
 	}
 
@@ -72,61 +112,44 @@ func Clone(token string, url string) (string, error) {
 		return dir, nil
 	}
 
+	_ = os.RemoveAll(dir)
+
 	return "", Error(fmt.Errorf("unable to clone the repository"))
 }
 
-// ParseModFile reads the go.mod file and returns the libraries that are required by the project.
-func ParseModFile(path string) ([]string, error) {
-	data, err := os.ReadFile(path + "/" + "go.mod")
+// CountCommits counts the commits reachable from HEAD of the repository cloned at path.
+func CountCommits(path string) (int, error) {
+	repo, err := git.PlainOpen(path)
 	if err != nil {
-		return nil, Error(err)
-	}
-
-	file, err := modfile.Parse("go.mod", data, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	libraries := make([]string, len(file.Require))
-
-	for _, r := range file.Require {
-		libraries = append(libraries, r.Mod.Path+"@"+r.Mod.Version)
+		return 0, Error(fmt.Errorf("unable to open the repository: %v", err))
 	}
 
-	return libraries, nil
-}
-
-// FetchLibrary creates a temporary module to fetch a library without affecting the main go.mod.
-func FetchLibrary(url string) (string, error) {
-	tempDir, err := os.MkdirTemp("", "temp-mod")
+	iter, err := repo.Log(&git.LogOptions{})
 	if err != nil {
-		return "", Error(fmt.Errorf("unable to create a temporary directory: %v", err))
-	}
-	defer func() { _ = os.RemoveAll(tempDir) }()
-
-	if err = os.Chdir(tempDir); err != nil {
-		return "", Error(fmt.Errorf("unable to change the working directory: %v", err))
-	}
-
-	if out, execErr := exec.Command("go", "mod", "init", "temp").CombinedOutput(); execErr != nil {
-		return "", Error(fmt.Errorf("unable to initialize the temporary module: %v", string(out)))
+		return 0, Error(fmt.Errorf("unable to read the commit log: %v", err))
 	}
+	defer iter.Close()
 
-	if out, execErr := exec.Command("go", "get", url).CombinedOutput(); execErr != nil {
-		return "", Error(fmt.Errorf("unable to fetch the library: %v", string(out)))
-	}
+	count := 0
 
-	p, err := exec.Command("go", "env", "GOMODCACHE").Output()
+	err = iter.ForEach(func(*object.Commit) error {
+		count++
+		return nil
+	})
 	if err != nil {
-		return "", nil
+		return 0, Error(fmt.Errorf("unable to count the commits: %v", err))
 	}
 
-	return filepath.Join(strings.TrimSpace(string(p)), url), nil
+	return count, nil
 }
 
+// ErrLanguageNotFound is returned by CalcLOC if none of the languages is found in the directory.
+var ErrLanguageNotFound = errors.New("language not found in analysis results")
+
 // CalcLOC is a method of the RepositoryService struct. It calculates the lines of code
-// of a directory based on the provided language.
-func CalcLOC(dir string, lang string) (int, error) {
+// of a directory based on the provided languages, summed up. Languages missing from the directory count as zero, as
+// long as at least one of them is found.
+func CalcLOC(dir string, langs ...string) (int, error) {
 	languages := gocloc.NewDefinedLanguages()
 	options := gocloc.NewClocOptions()
 
@@ -141,14 +164,29 @@ func CalcLOC(dir string, lang string) (int, error) {
 		return -1, Error(fmt.Errorf("unable to analyze the repository: %v", err))
 	}
 
-	report, ok := result.Languages[lang]
-	if ok {
-		return int(report.Code), nil
+	loc, found := 0, false
+
+	for _, lang := range langs {
+		names, ok := clocLanguages[strings.ToLower(lang)]
+		if !ok {
+			names = []string{lang}
+		}
+
+		for _, name := range names {
+			report, ok := result.Languages[name]
+			if !ok {
+				report, ok = result.Languages[strings.ToLower(name)]
+			}
+
+			if ok {
+				loc, found = loc+int(report.Code), true
+			}
+		}
 	}
 
-	if report, ok = result.Languages[strings.ToLower(lang)]; ok {
-		return int(report.Code), nil
+	if !found {
+		return 0, fmt.Errorf("%w: %s", ErrLanguageNotFound, strings.Join(langs, ", "))
 	}
 
-	return 0, fmt.Errorf("language %s not found in analysis results", lang)
+	return loc, nil
 }
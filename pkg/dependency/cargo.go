@@ -0,0 +1,167 @@
+package dependency
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Cargo resolves the dependencies of Rust projects from Cargo.lock, and fetches the crates from a registry.
+type Cargo struct {
+	download string
+	client   *http.Client
+}
+
+// NewCargo creates a Cargo resolver downloading crates from the given URL. The URL follows the "dl" setting of a Cargo
+// registry (https://doc.rust-lang.org/cargo/reference/registry-index.html#index-configuration): the markers {crate},
+// {version}, {prefix}, {lowerprefix} and {sha256-checksum} are replaced, and a URL without markers is followed by
+// "/{crate}/{version}/download".
+func NewCargo(download string, client *http.Client) *Cargo {
+	return &Cargo{
+		download: download,
+		client:   client,
+	}
+}
+
+// Languages is a method of the Cargo struct. It returns Rust.
+func (c *Cargo) Languages() []string {
+	return []string{"Rust"}
+}
+
+// Detect is a method of the Cargo struct. It reports whether dir contains Cargo.lock or Cargo.toml.
+func (c *Cargo) Detect(dir string) bool {
+	return exists(dir, "Cargo.lock", "Cargo.toml")
+}
+
+// Dependencies is a method of the Cargo struct. It returns the crates of Cargo.lock that come from a registry, which
+// includes the dependencies of every member of a workspace and their development dependencies. Crates from git
+// repositories are unresolved. Without Cargo.lock, the dependencies of Cargo.toml cannot be pinned to a version and are
+// all unresolved.
+func (c *Cargo) Dependencies(_ context.Context, dir string) ([]Dependency, []string, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "Cargo.lock"))
+	if errors.Is(err, os.ErrNotExist) {
+		return c.manifestDependencies(dir)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var lock struct {
+		Package []struct {
+			Name     string `toml:"name"`
+			Version  string `toml:"version"`
+			Source   string `toml:"source"`
+			Checksum string `toml:"checksum"`
+		} `toml:"package"`
+	}
+
+	if err = toml.Unmarshal(b, &lock); err != nil {
+		return nil, nil, fmt.Errorf("unable to parse Cargo.lock: %v", err)
+	}
+
+	var (
+		deps       []Dependency
+		unresolved []string
+	)
+
+	for _, p := range lock.Package {
+		dep := Dependency{Name: p.Name, Version: p.Version}
+
+		switch {
+		case p.Source == "":
+			// The crates of the workspace itself.
+			continue
+		case strings.HasPrefix(p.Source, "registry+") || strings.HasPrefix(p.Source, "sparse+"):
+			if sum, err := hex.DecodeString(p.Checksum); err == nil && p.Checksum != "" {
+				dep.Integrity = "sha256-" + base64.StdEncoding.EncodeToString(sum)
+			}
+
+			deps = append(deps, dep)
+		default:
+			unresolved = append(unresolved, dep.String())
+		}
+	}
+
+	return deps, unresolved, nil
+}
+
+// manifestDependencies is a method of the Cargo struct. It returns the dependencies of Cargo.toml as unresolved.
+func (c *Cargo) manifestDependencies(dir string) ([]Dependency, []string, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "Cargo.toml"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var manifest struct {
+		Dependencies map[string]any `toml:"dependencies"`
+	}
+
+	if err = toml.Unmarshal(b, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("unable to parse Cargo.toml: %v", err)
+	}
+
+	return nil, slices.Sorted(maps.Keys(manifest.Dependencies)), nil
+}
+
+// Fetch is a method of the Cargo struct. It downloads a crate, verifies it against the checksum of Cargo.lock, and
+// extracts it into dir.
+func (c *Cargo) Fetch(ctx context.Context, dep Dependency, dir string) error {
+	body, err := get(ctx, c.client, c.url(dep), "")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	if err = extractVerified(body, dep.Integrity, dir, extractTarGz); err != nil {
+		return fmt.Errorf("unable to extract %v: %v", dep, err)
+	}
+
+	return nil
+}
+
+// url is a method of the Cargo struct. It returns the download URL of a crate.
+func (c *Cargo) url(dep Dependency) string {
+	if !strings.Contains(c.download, "{") {
+		return fmt.Sprintf("%s/%s/%s/download", strings.TrimSuffix(c.download, "/"), dep.Name, dep.Version)
+	}
+
+	var checksum string
+	if _, digest := integrity(dep.Integrity); digest != nil {
+		checksum = hex.EncodeToString(digest)
+	}
+
+	return strings.NewReplacer(
+		"{crate}", dep.Name,
+		"{version}", dep.Version,
+		"{prefix}", cratePrefix(dep.Name),
+		"{lowerprefix}", strings.ToLower(cratePrefix(dep.Name)),
+		"{sha256-checksum}", checksum,
+	).Replace(c.download)
+}
+
+// cratePrefix returns the directory of a crate in a registry index, e.g. "se/rd" for "serde".
+func cratePrefix(name string) string {
+	switch len(name) {
+	case 1:
+		return "1"
+	case 2:
+		return "2"
+	case 3:
+		return "3/" + name[:1]
+	default:
+		return name[:2] + "/" + name[2:4]
+	}
+}
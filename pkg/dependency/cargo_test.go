@@ -0,0 +1,169 @@
+package dependency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCargoDependencies(t *testing.T) {
+	sum := sha256.Sum256([]byte("serde crate"))
+	checksum := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name           string
+		files          map[string]string
+		want           []Dependency
+		wantUnresolved []string
+		wantErr        bool
+	}{
+		{
+			name: "Cargo.lock",
+			files: map[string]string{
+				"Cargo.lock": `version = 3
+
+[[package]]
+name = "app"
+version = "0.1.0"
+dependencies = ["serde", "tokio"]
+
+[[package]]
+name = "serde"
+version = "1.0.193"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+checksum = "` + checksum + `"
+
+[[package]]
+name = "tokio"
+version = "1.35.0"
+source = "sparse+https://index.example.com/"
+
+[[package]]
+name = "broken-checksum"
+version = "0.1.0"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+checksum = "not hex"
+
+[[package]]
+name = "mylib"
+version = "0.2.0"
+source = "git+https://github.com/user/mylib.git#abc123"
+`,
+				"Cargo.toml": "[dependencies]\nserde = \"1\"\n",
+			},
+			want: []Dependency{
+				{Name: "serde", Version: "1.0.193", Integrity: sha256SRI([]byte("serde crate"))},
+				{Name: "tokio", Version: "1.35.0"},
+				{Name: "broken-checksum", Version: "0.1.0"},
+			},
+			wantUnresolved: []string{"mylib@0.2.0"},
+		},
+		{
+			name: "Cargo.toml without Cargo.lock",
+			files: map[string]string{
+				"Cargo.toml": "[package]\nname = \"app\"\n\n[dependencies]\ntokio = { version = \"1\", features = [\"full\"] }\nserde = \"1\"\n\n[dev-dependencies]\nproptest = \"1\"\n",
+			},
+			wantUnresolved: []string{"serde", "tokio"},
+		},
+		{
+			name:  "no manifest",
+			files: map[string]string{"README.md": "# app\n"},
+		},
+		{
+			name:    "malformed Cargo.lock",
+			files:   map[string]string{"Cargo.lock": "[[package]\n"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unresolved, err := NewCargo("", nil).Dependencies(context.Background(), writeFiles(t, tt.files))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Dependencies() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Dependencies() = %v, want %v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(unresolved, tt.wantUnresolved) {
+				t.Errorf("Dependencies() unresolved = %v, want %v", unresolved, tt.wantUnresolved)
+			}
+		})
+	}
+}
+
+func TestCargoURL(t *testing.T) {
+	sum := sha256.Sum256([]byte("crate"))
+	dep := Dependency{Name: "Serde", Version: "1.0.0", Integrity: sha256SRI([]byte("crate"))}
+
+	tests := []struct {
+		download string
+		want     string
+	}{
+		{download: "https://static.crates.io/crates/", want: "https://static.crates.io/crates/Serde/1.0.0/download"},
+		{download: "http://localhost:8081/{crate}/{crate}-{version}.crate", want: "http://localhost:8081/Serde/Serde-1.0.0.crate"},
+		{download: "https://example.com/{prefix}/{lowerprefix}/{crate}", want: "https://example.com/Se/rd/se/rd/Serde"},
+		{download: "https://example.com/{sha256-checksum}", want: "https://example.com/" + hex.EncodeToString(sum[:])},
+	}
+
+	for _, tt := range tests {
+		if got := NewCargo(tt.download, nil).url(dep); got != tt.want {
+			t.Errorf("url() with %q = %q, want %q", tt.download, got, tt.want)
+		}
+	}
+}
+
+func TestCratePrefix(t *testing.T) {
+	for name, want := range map[string]string{"a": "1", "ab": "2", "abc": "3/a", "serde": "se/rd"} {
+		if got := cratePrefix(name); got != want {
+			t.Errorf("cratePrefix(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCargoFetch(t *testing.T) {
+	crate := tarGz(t, map[string]string{"serde-1.0.0/src/lib.rs": "pub fn serde() {}\n"})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/serde/1.0.0/download" {
+			http.NotFound(w, r)
+			return
+		}
+
+		_, _ = w.Write(crate)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewCargo(srv.URL, srv.Client())
+
+	tests := []struct {
+		name    string
+		dep     Dependency
+		wantErr bool
+	}{
+		{name: "verified", dep: Dependency{Name: "serde", Version: "1.0.0", Integrity: sha256SRI(crate)}},
+		{name: "checksum mismatch", dep: Dependency{Name: "serde", Version: "1.0.0", Integrity: sha256SRI([]byte("other"))}, wantErr: true},
+		{name: "missing", dep: Dependency{Name: "serde", Version: "2.0.0"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			err := c.Fetch(context.Background(), tt.dep, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr {
+				readFile(t, dir, "serde-1.0.0/src/lib.rs")
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package dependency
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// maxExtractedSize limits the size of a single extracted package, so a hostile or broken archive cannot fill the disk.
+const maxExtractedSize = 1 << 30
+
+// Dependency is a third-party package a project depends on, pinned to a version.
+type Dependency struct {
+	Name    string
+	Version string
+
+	// Integrity is the Subresource Integrity (https://www.w3.org/TR/SRI/) of the package archive, e.g. "sha512-...",
+	// if the manifest records it. For Go modules, it is the hash of go.sum, e.g. "h1:...".
+	Integrity string
+
+	// URL is the location of the package archive, if it is known from resolving the dependency.
+	URL string
+}
+
+func (d Dependency) String() string {
+	return d.Name + "@" + d.Version
+}
+
+var errNotFound = errors.New("not found")
+
+// get requests url and returns the response body, which the caller must close.
+func get(ctx context.Context, client *http.Client, url string, accept string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch %v: %v", url, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+
+		// Module proxies answer 410 Gone for modules they do not serve.
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+			return nil, fmt.Errorf("unable to fetch %v: %w", url, errNotFound)
+		}
+
+		return nil, fmt.Errorf("unable to fetch %v: %v", url, resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// exists reports whether dir contains a file matching any of the glob patterns.
+func exists(dir string, patterns ...string) bool {
+	for _, pattern := range patterns {
+		if matches, _ := filepath.Glob(filepath.Join(dir, pattern)); len(matches) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unique sorts the dependencies and removes repeated ones, which lockfiles list once for every place they are
+// installed at.
+func unique(deps []Dependency) []Dependency {
+	slices.SortFunc(deps, func(a, b Dependency) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	seen := make(map[string]bool, len(deps))
+	result := deps[:0]
+
+	for _, d := range deps {
+		if !seen[d.String()] {
+			seen[d.String()] = true
+			result = append(result, d)
+		}
+	}
+
+	return result
+}
+
+// extractVerified extracts the archive read from r into dst with extract, and verifies the archive against the
+// Subresource Integrity sri afterwards. An archive without a known integrity is extracted without verification.
+func extractVerified(r io.Reader, sri string, dst string, extract func(io.Reader, string) error) error {
+	h, digest := integrity(sri)
+	if h != nil {
+		r = io.TeeReader(r, h)
+	}
+
+	if err := extract(r, dst); err != nil {
+		return err
+	}
+
+	if h != nil && !bytes.Equal(h.Sum(nil), digest) {
+		return fmt.Errorf("the archive does not match its integrity %v", sri)
+	}
+
+	return nil
+}
+
+// integrity parses a Subresource Integrity string and returns a hash to compute it with, and the expected digest. The
+// strongest supported hash is used when the string lists several. It returns a nil hash if the integrity is empty or
+// uses no supported algorithm.
+func integrity(sri string) (hash.Hash, []byte) {
+	var (
+		h      hash.Hash
+		digest []byte
+		rank   int
+	)
+
+	for _, entry := range strings.Fields(sri) {
+		algorithm, value, ok := strings.Cut(entry, "-")
+		if !ok {
+			continue
+		}
+
+		// Options may follow the digest after a question mark.
+		value, _, _ = strings.Cut(value, "?")
+
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case algorithm == "sha512" && rank < 3:
+			h, digest, rank = sha512.New(), b, 3
+		case algorithm == "sha256" && rank < 2:
+			h, digest, rank = sha256.New(), b, 2
+		case algorithm == "sha1" && rank < 1:
+			h, digest, rank = sha1.New(), b, 1
+		}
+	}
+
+	return h, digest
+}
+
+// extractTarGz extracts a gzip compressed tar archive into dst. Only regular files and directories are extracted,
+// and entries that would end up outside of dst are rejected.
+func extractTarGz(r io.Reader, dst string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("unable to decompress the archive: %v", err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	tr := tar.NewReader(gz)
+
+	var size int64
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read the archive: %v", err)
+		}
+
+		path, err := within(dst, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if size += header.Size; size > maxExtractedSize {
+				return fmt.Errorf("the archive is larger than %v bytes", maxExtractedSize)
+			}
+
+			if err = writeFile(path, io.LimitReader(tr, header.Size)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Drain the rest of the stream, so a hash computed over it covers the whole archive.
+	_, err = io.Copy(io.Discard, r)
+
+	return err
+}
+
+// extractZip extracts a zip archive, e.g. a Python wheel, into dst. The archive is buffered in a temporary file, as
+// zip archives can only be read with random access. Only regular files are extracted, and entries that would end up
+// outside of dst are rejected.
+func extractZip(r io.Reader, dst string) error {
+	f, err := os.CreateTemp("", "archive-*.zip")
+	if err != nil {
+		return fmt.Errorf("unable to create a temporary file: %v", err)
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	n, err := io.Copy(f, io.LimitReader(r, maxExtractedSize+1))
+	if err != nil {
+		return fmt.Errorf("unable to read the archive: %v", err)
+	}
+
+	if n > maxExtractedSize {
+		return fmt.Errorf("the archive is larger than %v bytes", maxExtractedSize)
+	}
+
+	zr, err := zip.NewReader(f, n)
+	if err != nil {
+		return fmt.Errorf("unable to read the archive: %v", err)
+	}
+
+	var size uint64
+
+	for _, entry := range zr.File {
+		path, err := within(dst, entry.Name)
+		if err != nil {
+			return err
+		}
+
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+
+		if size += entry.UncompressedSize64; size > maxExtractedSize {
+			return fmt.Errorf("the archive is larger than %v bytes", maxExtractedSize)
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("unable to read the archive: %v", err)
+		}
+
+		err = writeFile(path, io.LimitReader(rc, int64(entry.UncompressedSize64)))
+		_ = rc.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// within returns the path of an archive entry inside dst, or an error if the entry would escape dst.
+func within(dst string, name string) (string, error) {
+	path := filepath.Join(dst, filepath.FromSlash(name))
+
+	if path != dst && !strings.HasPrefix(path, dst+string(filepath.Separator)) {
+		return "", fmt.Errorf("the archive entry %v points outside of the extraction directory", name)
+	}
+
+	return path, nil
+}
+
+func writeFile(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
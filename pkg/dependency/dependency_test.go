@@ -0,0 +1,230 @@
+package dependency
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// tarGz returns a gzip compressed tar archive of the given files, keyed by their path in the archive.
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, name := range sortedKeys(files) {
+		header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// zipArchive returns a zip archive of the given files, keyed by their path in the archive.
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	zw := zip.NewWriter(&buf)
+
+	for _, name := range sortedKeys(files) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = w.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func sortedKeys(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+func sha512SRI(b []byte) string {
+	sum := sha512.Sum512(b)
+	return "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func sha1SRI(b []byte) string {
+	sum := sha1.Sum(b)
+	return "sha1-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func sha256SRI(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// writeFiles writes the given files, keyed by their path, into a new temporary directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for name, content := range files {
+		if err := writeFile(filepath.Join(dir, filepath.FromSlash(name)), bytes.NewReader([]byte(content))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// readFile returns the content of a file below dir, or fails the test.
+func readFile(t *testing.T, dir string, name string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
+
+func TestIntegrity(t *testing.T) {
+	data := []byte("content")
+
+	tests := []struct {
+		name string
+		sri  string
+		want string
+	}{
+		{name: "empty", sri: "", want: ""},
+		{name: "sha512", sri: sha512SRI(data), want: sha512SRI(data)},
+		{name: "strongest of several", sri: sha256SRI(data) + " " + sha512SRI(data), want: sha512SRI(data)},
+		{name: "options", sri: sha256SRI(data) + "?foo", want: sha256SRI(data)},
+		{name: "unsupported algorithm", sri: "md5-AAAA", want: ""},
+		{name: "malformed digest", sri: "sha512-%%%", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, digest := integrity(tt.sri)
+
+			if tt.want == "" {
+				if h != nil {
+					t.Fatalf("integrity(%q) returned a hash, want none", tt.sri)
+				}
+
+				return
+			}
+
+			if h == nil {
+				t.Fatalf("integrity(%q) returned no hash", tt.sri)
+			}
+
+			_, want := integrity(tt.want)
+			if !bytes.Equal(digest, want) {
+				t.Errorf("integrity(%q) digest = %x, want %x", tt.sri, digest, want)
+			}
+		})
+	}
+}
+
+func TestExtractVerified(t *testing.T) {
+	archive := tarGz(t, map[string]string{"package/index.js": "module.exports = 1\n"})
+
+	tests := []struct {
+		name    string
+		sri     string
+		wantErr bool
+	}{
+		{name: "matching", sri: sha512SRI(archive)},
+		{name: "unknown", sri: ""},
+		{name: "mismatching", sri: sha512SRI([]byte("other")), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			err := extractVerified(bytes.NewReader(archive), tt.sri, dir, extractTarGz)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("extractVerified() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr {
+				if got := readFile(t, dir, "package/index.js"); got != "module.exports = 1\n" {
+					t.Errorf("extracted file = %q", got)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractRejectsEscapingEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive []byte
+		extract func([]byte, string) error
+	}{
+		{
+			name:    "tar",
+			archive: tarGz(t, map[string]string{"../escape.txt": "x"}),
+			extract: func(b []byte, dst string) error { return extractTarGz(bytes.NewReader(b), dst) },
+		},
+		{
+			name:    "zip",
+			archive: zipArchive(t, map[string]string{"../escape.txt": "x"}),
+			extract: func(b []byte, dst string) error { return extractZip(bytes.NewReader(b), dst) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := filepath.Join(t.TempDir(), "dst")
+
+			if err := tt.extract(tt.archive, dst); err == nil {
+				t.Fatal("extracting an entry outside of the directory succeeded")
+			}
+
+			if _, err := os.Stat(filepath.Join(filepath.Dir(dst), "escape.txt")); err == nil {
+				t.Fatal("the escaping entry was written")
+			}
+		})
+	}
+}
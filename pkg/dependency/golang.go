@@ -0,0 +1,333 @@
+package dependency
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb/dirhash"
+	modzip "golang.org/x/mod/zip"
+)
+
+// Go resolves the dependencies of Go modules from go.mod, and fetches them from module proxies
+// (https://go.dev/ref/mod#goproxy-protocol).
+type Go struct {
+	proxies []goProxy
+	cache   string
+	client  *http.Client
+}
+
+// goProxy is an entry of a GOPROXY list. fallback is set if the next proxy is tried after any error, not only if the
+// proxy does not have the module.
+type goProxy struct {
+	url      string
+	fallback bool
+}
+
+// NewGo creates a Go resolver for the proxies of a GOPROXY setting, e.g. "https://proxy.golang.org" or
+// "file:///srv/goproxy,https://proxy.golang.org". The module zips are kept in cache, laid out like a proxy, so the
+// directory can be served with a file:// URL itself. An empty cache keeps nothing.
+func NewGo(goproxy string, cache string, client *http.Client) *Go {
+	g := &Go{
+		cache:  cache,
+		client: client,
+	}
+
+	for goproxy != "" {
+		entry, rest := goproxy, ""
+		fallback := false
+
+		if i := strings.IndexAny(goproxy, ",|"); i >= 0 {
+			entry, rest, fallback = goproxy[:i], goproxy[i+1:], goproxy[i] == '|'
+		}
+
+		goproxy = rest
+
+		// Fetching from version control systems directly needs the go command.
+		if entry = strings.TrimSpace(entry); entry == "" || entry == "direct" || entry == "off" {
+			continue
+		}
+
+		g.proxies = append(g.proxies, goProxy{url: strings.TrimSuffix(entry, "/"), fallback: fallback})
+	}
+
+	return g
+}
+
+// Languages is a method of the Go struct. It returns Go.
+func (g *Go) Languages() []string {
+	return []string{"Go"}
+}
+
+// Detect is a method of the Go struct. It reports whether dir contains go.mod.
+func (g *Go) Detect(dir string) bool {
+	return exists(dir, "go.mod")
+}
+
+// Dependencies is a method of the Go struct. It returns the modules required by go.mod, including the indirect ones,
+// with their hashes from go.sum. Modules replaced by a local directory are unresolved.
+func (g *Go) Dependencies(_ context.Context, dir string) ([]Dependency, []string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := modfile.Parse("go.mod", data, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sums, err := readGoSum(filepath.Join(dir, "go.sum"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replacements := make(map[module.Version]module.Version)
+
+	for _, r := range file.Replace {
+		replacements[r.Old] = r.New
+	}
+
+	var (
+		deps       []Dependency
+		unresolved []string
+	)
+
+	for _, r := range file.Require {
+		m, ok := replacements[r.Mod]
+		if !ok {
+			if m, ok = replacements[module.Version{Path: r.Mod.Path}]; !ok {
+				m = r.Mod
+			}
+		}
+
+		// A replacement without a version is a directory.
+		if m.Version == "" {
+			unresolved = append(unresolved, r.Mod.String())
+			continue
+		}
+
+		deps = append(deps, Dependency{Name: m.Path, Version: m.Version, Integrity: sums[m]})
+	}
+
+	return deps, unresolved, nil
+}
+
+// Fetch is a method of the Go struct. It downloads the zip of a module from the proxies, unless the cache has it,
+// verifies it against the hash of go.sum, and extracts it into dir. Modules without a hash in go.sum are extracted
+// without verification, and are not added to the cache.
+func (g *Go) Fetch(ctx context.Context, dep Dependency, dir string) error {
+	m := module.Version{Path: dep.Name, Version: dep.Version}
+
+	zipFile, cleanup, err := g.download(ctx, m, dep.Integrity)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if err = modzip.Unzip(dir, m, zipFile); err != nil {
+		return fmt.Errorf("unable to extract %v: %v", dep, err)
+	}
+
+	return nil
+}
+
+// download is a method of the Go struct. It returns the path of the zip of a module, verified against the go.sum hash
+// if known, and a function to call once the zip is no longer needed. Only verified zips are added to the cache, and a
+// cached zip not matching the hash is replaced by a fresh download.
+func (g *Go) download(ctx context.Context, m module.Version, sum string) (string, func(), error) {
+	zipPath, err := moduleZipPath(m)
+	if err != nil {
+		return "", nil, err
+	}
+
+	dst := ""
+	if g.cache != "" {
+		dst = filepath.Join(g.cache, filepath.FromSlash(zipPath))
+
+		if _, err = os.Stat(dst); err == nil {
+			if err = verifyZip(dst, m, sum); err == nil {
+				return dst, func() {}, nil
+			}
+
+			// The cache may be shared or served as a proxy, so a corrupt zip is not kept around.
+			if err = os.Remove(dst); err != nil {
+				return "", nil, fmt.Errorf("unable to remove %v from the module cache: %v", m, err)
+			}
+		}
+	}
+
+	// Without a hash, the zip is not cached.
+	if sum == "" {
+		dst = ""
+	}
+
+	// The zip is written to a temporary file first, so concurrent downloads of the same module do not see a partial zip.
+	tmpDir := ""
+	if dst != "" {
+		tmpDir = filepath.Dir(dst)
+
+		if err = os.MkdirAll(tmpDir, 0o755); err != nil {
+			return "", nil, fmt.Errorf("unable to create the module cache: %v", err)
+		}
+	}
+
+	f, err := os.CreateTemp(tmpDir, "module-*.zip")
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to create a temporary file: %v", err)
+	}
+
+	tmp := f.Name()
+	remove := func() { _ = os.Remove(tmp) }
+
+	err = g.copyZip(ctx, f, zipPath)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		remove()
+		return "", nil, fmt.Errorf("unable to download %v: %v", m, err)
+	}
+
+	if err = verifyZip(tmp, m, sum); err != nil {
+		remove()
+		return "", nil, err
+	}
+
+	if dst == "" {
+		return tmp, remove, nil
+	}
+
+	if err = os.Rename(tmp, dst); err != nil {
+		remove()
+		return "", nil, fmt.Errorf("unable to cache %v: %v", m, err)
+	}
+
+	return dst, func() {}, nil
+}
+
+// copyZip is a method of the Go struct. It copies a zip from the first proxy that has it into f.
+func (g *Go) copyZip(ctx context.Context, f *os.File, zipPath string) error {
+	if len(g.proxies) == 0 {
+		return errors.New("no module proxy is configured")
+	}
+
+	var errs []error
+
+	for _, proxy := range g.proxies {
+		body, err := g.open(ctx, proxy.url+"/"+zipPath)
+		if err == nil {
+			// A proxy failing halfway may have left a partial zip behind.
+			if err = f.Truncate(0); err == nil {
+				if _, err = f.Seek(0, io.SeekStart); err == nil {
+					_, err = io.Copy(f, io.LimitReader(body, modzip.MaxZipFile))
+				}
+			}
+
+			_ = body.Close()
+
+			if err == nil {
+				return nil
+			}
+		}
+
+		errs = append(errs, err)
+
+		// Only a proxy that does not have the module falls back to the next one, unless the list says otherwise.
+		if !proxy.fallback && !errors.Is(err, errNotFound) {
+			break
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// open is a method of the Go struct. It opens a file of a proxy, either on the local file system or over HTTP.
+func (g *Go) open(ctx context.Context, u string) (io.ReadCloser, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+
+	if parsed.Scheme != "file" {
+		return get(ctx, g.client, u, "")
+	}
+
+	f, err := os.Open(filepath.FromSlash(parsed.Path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("unable to open %v: %w", u, errNotFound)
+	}
+
+	return f, err
+}
+
+// verifyZip compares the hash of a module zip with its hash from go.sum. An empty hash is not verified.
+func verifyZip(zipFile string, m module.Version, sum string) error {
+	if sum == "" {
+		return nil
+	}
+
+	got, err := dirhash.HashZip(zipFile, dirhash.Hash1)
+	if err != nil {
+		return fmt.Errorf("unable to hash %v: %v", m, err)
+	}
+
+	if got != sum {
+		return fmt.Errorf("%v does not match go.sum: got %v, want %v", m, got, sum)
+	}
+
+	return nil
+}
+
+// moduleZipPath returns the path of the zip of a module below the root of a proxy, with upper case letters escaped.
+func moduleZipPath(m module.Version) (string, error) {
+	path, err := module.EscapePath(m.Path)
+	if err != nil {
+		return "", err
+	}
+
+	version, err := module.EscapeVersion(m.Version)
+	if err != nil {
+		return "", err
+	}
+
+	return path + "/@v/" + version + ".zip", nil
+}
+
+// readGoSum reads the hashes of the module zips of a go.sum file. A missing go.sum has no hashes.
+func readGoSum(path string) (map[module.Version]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sums := make(map[module.Version]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for scanner.Scan() {
+		// Each line is "path version hash", the hashes of go.mod files have a version ending in "/go.mod".
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+
+		sums[module.Version{Path: fields[0], Version: fields[1]}] = fields[2]
+	}
+
+	return sums, scanner.Err()
+}
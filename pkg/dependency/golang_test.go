@@ -0,0 +1,332 @@
+package dependency
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb/dirhash"
+	modzip "golang.org/x/mod/zip"
+)
+
+// goModuleProxy writes the zips of the given modules into a new directory laid out like a module proxy, and returns the
+// directory and the go.sum hashes of the zips.
+func goModuleProxy(t *testing.T, modules ...module.Version) (string, map[module.Version]string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	sums := make(map[module.Version]string)
+
+	for _, m := range modules {
+		src := writeFiles(t, map[string]string{
+			"go.mod": "module " + m.Path + "\n",
+			"lib.go": "package lib\n\nconst Version = \"" + m.Version + "\"\n",
+		})
+
+		var buf bytes.Buffer
+		if err := modzip.CreateFromDir(&buf, m, src); err != nil {
+			t.Fatal(err)
+		}
+
+		zipPath, err := moduleZipPath(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		zipFile := filepath.Join(dir, filepath.FromSlash(zipPath))
+		if err = writeFile(zipFile, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if sums[m], err = dirhash.HashZip(zipFile, dirhash.Hash1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir, sums
+}
+
+func fileURL(dir string) string {
+	return "file://" + filepath.ToSlash(dir)
+}
+
+func TestNewGo(t *testing.T) {
+	tests := []struct {
+		goproxy string
+		want    []goProxy
+	}{
+		{goproxy: "https://proxy.golang.org", want: []goProxy{{url: "https://proxy.golang.org"}}},
+		{
+			goproxy: "file:///srv/goproxy/,https://proxy.golang.org|https://goproxy.io,direct",
+			want: []goProxy{
+				{url: "file:///srv/goproxy"},
+				{url: "https://proxy.golang.org", fallback: true},
+				{url: "https://goproxy.io"},
+			},
+		},
+		{goproxy: "direct|https://proxy.golang.org", want: []goProxy{{url: "https://proxy.golang.org"}}},
+		{goproxy: " off ", want: nil},
+		{goproxy: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.goproxy, func(t *testing.T) {
+			if got := NewGo(tt.goproxy, "", nil).proxies; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewGo(%q) proxies = %+v, want %+v", tt.goproxy, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoCopyZip(t *testing.T) {
+	m := module.Version{Path: "github.com/User/lib", Version: "v1.0.0"}
+
+	proxy, _ := goModuleProxy(t, m)
+	empty := t.TempDir()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(failing.Close)
+
+	zipPath, err := moduleZipPath(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte(readFile(t, proxy, zipPath))
+
+	tests := []struct {
+		name         string
+		goproxy      string
+		wantErr      bool
+		wantNotFound bool
+	}{
+		{name: "found", goproxy: fileURL(proxy)},
+		{name: "missing falls back", goproxy: fileURL(empty) + "," + fileURL(proxy)},
+		{name: "failing does not fall back", goproxy: failing.URL + "," + fileURL(proxy), wantErr: true},
+		{name: "failing falls back after pipe", goproxy: failing.URL + "|" + fileURL(proxy)},
+		{name: "missing everywhere", goproxy: fileURL(empty) + "|" + fileURL(empty), wantErr: true, wantNotFound: true},
+		{name: "no proxy", goproxy: "direct", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.CreateTemp(t.TempDir(), "module-*.zip")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = f.Close() }()
+
+			err = NewGo(tt.goproxy, "", failing.Client()).copyZip(context.Background(), f, zipPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("copyZip() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if errors.Is(err, errNotFound) != tt.wantNotFound {
+				t.Errorf("copyZip() error = %v, want not found %v", err, tt.wantNotFound)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got, _ := os.ReadFile(f.Name()); !bytes.Equal(got, want) {
+				t.Errorf("copyZip() copied %d bytes, want the %d bytes of the zip", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestReadGoSum(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"go.sum": `example.com/a v1.0.0 h1:a=
+example.com/a v1.0.0/go.mod h1:amod=
+example.com/b v0.2.0-pre h1:b=
+
+malformed line
+example.com/c v1.0.0/go.mod h1:cmod=
+`,
+	})
+
+	got, err := readGoSum(filepath.Join(dir, "go.sum"))
+	if err != nil {
+		t.Fatalf("readGoSum() error = %v", err)
+	}
+
+	want := map[module.Version]string{
+		{Path: "example.com/a", Version: "v1.0.0"}:     "h1:a=",
+		{Path: "example.com/b", Version: "v0.2.0-pre"}: "h1:b=",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readGoSum() = %v, want %v", got, want)
+	}
+
+	if got, err = readGoSum(filepath.Join(dir, "missing.sum")); got != nil || err != nil {
+		t.Errorf("readGoSum() of a missing file = %v, %v, want no hashes", got, err)
+	}
+}
+
+func TestGoDependencies(t *testing.T) {
+	tests := []struct {
+		name           string
+		files          map[string]string
+		want           []Dependency
+		wantUnresolved []string
+		wantErr        bool
+	}{
+		{
+			name: "replacements",
+			files: map[string]string{
+				"go.mod": `module example.com/app
+
+go 1.22
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.1.0 // indirect
+	example.com/c v0.1.0
+	example.com/d v2.0.0+incompatible
+)
+
+replace example.com/b => example.com/fork/b v1.2.0
+
+replace example.com/c v0.1.0 => ../c
+
+replace example.com/d v1.0.0 => example.com/other v1.0.0
+`,
+				"go.sum": `example.com/a v1.0.0 h1:a=
+example.com/a v1.0.0/go.mod h1:amod=
+example.com/b v1.1.0 h1:b=
+example.com/fork/b v1.2.0 h1:forkb=
+`,
+			},
+			want: []Dependency{
+				{Name: "example.com/a", Version: "v1.0.0", Integrity: "h1:a="},
+				{Name: "example.com/fork/b", Version: "v1.2.0", Integrity: "h1:forkb="},
+				{Name: "example.com/d", Version: "v2.0.0+incompatible"},
+			},
+			wantUnresolved: []string{"example.com/c@v0.1.0"},
+		},
+		{
+			name:  "without go.sum",
+			files: map[string]string{"go.mod": "module example.com/app\n\nrequire example.com/a v1.0.0\n"},
+			want:  []Dependency{{Name: "example.com/a", Version: "v1.0.0"}},
+		},
+		{
+			name:    "malformed go.mod",
+			files:   map[string]string{"go.mod": "module example.com/app\n\nrequire (\n"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unresolved, err := NewGo("", "", nil).Dependencies(context.Background(), writeFiles(t, tt.files))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Dependencies() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Dependencies() = %v, want %v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(unresolved, tt.wantUnresolved) {
+				t.Errorf("Dependencies() unresolved = %v, want %v", unresolved, tt.wantUnresolved)
+			}
+		})
+	}
+}
+
+func TestGoFetch(t *testing.T) {
+	m := module.Version{Path: "github.com/User/lib", Version: "v1.0.0"}
+
+	proxy, sums := goModuleProxy(t, m)
+
+	zipPath, err := moduleZipPath(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		dep        Dependency
+		cached     string
+		wantErr    bool
+		wantCached bool
+	}{
+		{
+			name:       "verified",
+			dep:        Dependency{Name: m.Path, Version: m.Version, Integrity: sums[m]},
+			wantCached: true,
+		},
+		{
+			name:    "mismatch",
+			dep:     Dependency{Name: m.Path, Version: m.Version, Integrity: "h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+			wantErr: true,
+		},
+		{
+			name: "without hash",
+			dep:  Dependency{Name: m.Path, Version: m.Version},
+		},
+		{
+			name:       "cached mismatch is downloaded again",
+			dep:        Dependency{Name: m.Path, Version: m.Version, Integrity: sums[m]},
+			cached:     "not a zip",
+			wantCached: true,
+		},
+		{
+			name:    "missing",
+			dep:     Dependency{Name: "github.com/User/other", Version: "v1.0.0"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := t.TempDir()
+			cachedZip := filepath.Join(cache, filepath.FromSlash(zipPath))
+
+			if tt.cached != "" {
+				if err := writeFile(cachedZip, bytes.NewReader([]byte(tt.cached))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			dir := t.TempDir()
+
+			err := NewGo(fileURL(proxy), cache, nil).Fetch(context.Background(), tt.dep, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr {
+				if got := readFile(t, dir, "lib.go"); got != "package lib\n\nconst Version = \"v1.0.0\"\n" {
+					t.Errorf("extracted lib.go = %q", got)
+				}
+			}
+
+			if tt.wantCached {
+				if got := readFile(t, cache, zipPath); got != readFile(t, proxy, zipPath) {
+					t.Error("the cache does not hold the zip of the proxy")
+				}
+			} else if _, err := os.Stat(cachedZip); err == nil {
+				t.Error("an unverified zip was cached")
+			}
+
+			// Only the zip may be left in the cache, not temporary files.
+			entries, err := os.ReadDir(filepath.Dir(cachedZip))
+			if err == nil && len(entries) > 1 {
+				t.Errorf("the cache holds %d files, want at most the zip", len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package dependency
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// propertyPattern matches a property reference of a pom.xml, e.g. "${jackson.version}".
+var propertyPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// Maven resolves the dependencies of Java and Kotlin projects from gradle.lockfile or pom.xml, and fetches their
+// source jars from a Maven repository.
+type Maven struct {
+	repository string
+	client     *http.Client
+}
+
+// NewMaven creates a Maven resolver for the repository at the given URL, e.g. https://repo.maven.apache.org/maven2.
+func NewMaven(repository string, client *http.Client) *Maven {
+	return &Maven{
+		repository: strings.TrimSuffix(repository, "/"),
+		client:     client,
+	}
+}
+
+// Languages is a method of the Maven struct. It returns Java and Kotlin.
+func (m *Maven) Languages() []string {
+	return []string{"Java", "Kotlin"}
+}
+
+// Detect is a method of the Maven struct. It reports whether dir contains gradle.lockfile or pom.xml.
+func (m *Maven) Detect(dir string) bool {
+	return exists(dir, "gradle.lockfile", "pom.xml")
+}
+
+// Dependencies is a method of the Maven struct. It returns the dependencies of the project in dir, named
+// "groupId:artifactId". gradle.lockfile pins every dependency of a Gradle build and takes precedence over pom.xml, whose
+// direct dependencies are read without resolving parent poms, so dependencies without a version or with a version range
+// are unresolved. Test dependencies are left out, and so are provided and optional dependencies of pom.xml.
+func (m *Maven) Dependencies(_ context.Context, dir string) ([]Dependency, []string, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "gradle.lockfile"))
+	if err == nil {
+		return parseGradleLockfile(b)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	if b, err = os.ReadFile(filepath.Join(dir, "pom.xml")); errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deps, unresolved, err := parsePOM(b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse pom.xml: %v", err)
+	}
+
+	return deps, unresolved, nil
+}
+
+// Fetch is a method of the Maven struct. It downloads the source jar of a dependency, verifies it against the SHA-1
+// checksum the repository publishes next to it, if any, and extracts it into dir.
+func (m *Maven) Fetch(ctx context.Context, dep Dependency, dir string) error {
+	group, artifact, ok := strings.Cut(dep.Name, ":")
+	if !ok {
+		return fmt.Errorf("invalid Maven coordinates %v", dep.Name)
+	}
+
+	u := fmt.Sprintf("%s/%s/%s/%s/%s-%s-sources.jar", m.repository, strings.ReplaceAll(group, ".", "/"), artifact,
+		dep.Version, artifact, dep.Version)
+
+	integrity, err := m.checksum(ctx, u+".sha1")
+	if err != nil {
+		return err
+	}
+
+	body, err := get(ctx, m.client, u, "")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	if err = extractVerified(body, integrity, dir, extractZip); err != nil {
+		return fmt.Errorf("unable to extract %v: %v", dep, err)
+	}
+
+	return nil
+}
+
+// checksum is a method of the Maven struct. It reads a SHA-1 checksum file of the repository and returns it as a
+// Subresource Integrity, or an empty string if the repository has none.
+func (m *Maven) checksum(ctx context.Context, url string) (string, error) {
+	body, err := get(ctx, m.client, url, "")
+	if errors.Is(err, errNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = body.Close() }()
+
+	b, err := io.ReadAll(io.LimitReader(body, 1024))
+	if err != nil {
+		return "", err
+	}
+
+	// Some checksum files are followed by the name of the file.
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return "", nil
+	}
+
+	sum, err := hex.DecodeString(fields[0])
+	if err != nil {
+		return "", fmt.Errorf("invalid checksum at %v", url)
+	}
+
+	return "sha1-" + base64.StdEncoding.EncodeToString(sum), nil
+}
+
+// parseGradleLockfile parses a Gradle dependency lockfile, which lists one "group:artifact:version=configurations"
+// line per dependency. Dependencies only used by test configurations are left out.
+func parseGradleLockfile(b []byte) ([]Dependency, []string, error) {
+	var deps []Dependency
+
+	scanner := bufio.NewScanner(strings.NewReader(string(b)))
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "empty=") {
+			continue
+		}
+
+		coordinates, configurations, _ := strings.Cut(line, "=")
+
+		parts := strings.Split(coordinates, ":")
+		if len(parts) != 3 || !nonTestConfiguration(configurations) {
+			continue
+		}
+
+		deps = append(deps, Dependency{Name: parts[0] + ":" + parts[1], Version: parts[2]})
+	}
+
+	return deps, nil, scanner.Err()
+}
+
+func nonTestConfiguration(configurations string) bool {
+	for _, configuration := range strings.Split(configurations, ",") {
+		if c := strings.ToLower(strings.TrimSpace(configuration)); c != "" && !strings.HasPrefix(c, "test") {
+			return true
+		}
+	}
+
+	return false
+}
+
+type pomDependency struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	Version    string `xml:"version"`
+	Scope      string `xml:"scope"`
+	Optional   string `xml:"optional"`
+}
+
+type pomProperties struct {
+	Entries []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+// parsePOM parses the dependencies of a pom.xml. Versions may refer to the properties of the pom, and to the versions
+// of its dependency management.
+func parsePOM(b []byte) ([]Dependency, []string, error) {
+	var pom struct {
+		GroupID string `xml:"groupId"`
+		Version string `xml:"version"`
+		Parent  struct {
+			GroupID string `xml:"groupId"`
+			Version string `xml:"version"`
+		} `xml:"parent"`
+		Properties           pomProperties   `xml:"properties"`
+		Dependencies         []pomDependency `xml:"dependencies>dependency"`
+		DependencyManagement []pomDependency `xml:"dependencyManagement>dependencies>dependency"`
+	}
+
+	if err := xml.Unmarshal(b, &pom); err != nil {
+		return nil, nil, err
+	}
+
+	properties := map[string]string{
+		"project.groupId":        cmp.Or(pom.GroupID, pom.Parent.GroupID),
+		"project.version":        cmp.Or(pom.Version, pom.Parent.Version),
+		"project.parent.groupId": pom.Parent.GroupID,
+		"project.parent.version": pom.Parent.Version,
+	}
+
+	for _, entry := range pom.Properties.Entries {
+		properties[entry.XMLName.Local] = strings.TrimSpace(entry.Value)
+	}
+
+	// Properties may refer to other properties, a few rounds resolve any sensible nesting.
+	expand := func(s string) string {
+		for range 5 {
+			s = propertyPattern.ReplaceAllStringFunc(s, func(ref string) string {
+				if value, ok := properties[ref[2:len(ref)-1]]; ok {
+					return value
+				}
+
+				return ref
+			})
+		}
+
+		return strings.TrimSpace(s)
+	}
+
+	managed := make(map[string]string)
+
+	for _, d := range pom.DependencyManagement {
+		managed[expand(d.GroupID)+":"+expand(d.ArtifactID)] = expand(d.Version)
+	}
+
+	var (
+		deps       []Dependency
+		unresolved []string
+	)
+
+	for _, d := range pom.Dependencies {
+		switch strings.TrimSpace(d.Scope) {
+		case "test", "provided", "system", "import":
+			continue
+		}
+
+		if strings.TrimSpace(d.Optional) == "true" {
+			continue
+		}
+
+		name := expand(d.GroupID) + ":" + expand(d.ArtifactID)
+
+		version := expand(d.Version)
+		if version == "" {
+			version = managed[name]
+		}
+
+		// Ranges such as "[1.0,2.0)" and unknown properties cannot be resolved without the repository and the parent.
+		if version == "" || strings.ContainsAny(version, "[]()${},") {
+			unresolved = append(unresolved, strings.TrimSuffix(name+"@"+version, "@"))
+			continue
+		}
+
+		deps = append(deps, Dependency{Name: name, Version: version})
+	}
+
+	return deps, unresolved, nil
+}
@@ -0,0 +1,325 @@
+package dependency
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParsePOM(t *testing.T) {
+	tests := []struct {
+		name           string
+		pom            string
+		want           []Dependency
+		wantUnresolved []string
+		wantErr        bool
+	}{
+		{
+			name: "properties",
+			pom: `<project>
+  <groupId>com.example</groupId>
+  <artifactId>app</artifactId>
+  <version>2.1.0</version>
+  <properties>
+    <jackson.major>2.16</jackson.major>
+    <jackson.version>${jackson.major}.1</jackson.version>
+    <guava.version> 33.0.0-jre </guava.version>
+  </properties>
+  <dependencies>
+    <dependency>
+      <groupId>com.fasterxml.jackson.core</groupId>
+      <artifactId>jackson-databind</artifactId>
+      <version>${jackson.version}</version>
+    </dependency>
+    <dependency>
+      <groupId>com.google.guava</groupId>
+      <artifactId>guava</artifactId>
+      <version>${guava.version}</version>
+    </dependency>
+    <dependency>
+      <groupId>${project.groupId}</groupId>
+      <artifactId>core</artifactId>
+      <version>${project.version}</version>
+    </dependency>
+  </dependencies>
+</project>`,
+			want: []Dependency{
+				{Name: "com.fasterxml.jackson.core:jackson-databind", Version: "2.16.1"},
+				{Name: "com.google.guava:guava", Version: "33.0.0-jre"},
+				{Name: "com.example:core", Version: "2.1.0"},
+			},
+		},
+		{
+			name: "parent",
+			pom: `<project>
+  <parent>
+    <groupId>com.example</groupId>
+    <artifactId>parent</artifactId>
+    <version>3.0.0</version>
+  </parent>
+  <artifactId>module</artifactId>
+  <dependencies>
+    <dependency>
+      <groupId>${project.groupId}</groupId>
+      <artifactId>api</artifactId>
+      <version>${project.version}</version>
+    </dependency>
+    <dependency>
+      <groupId>${project.parent.groupId}</groupId>
+      <artifactId>util</artifactId>
+      <version>${project.parent.version}</version>
+    </dependency>
+  </dependencies>
+</project>`,
+			want: []Dependency{
+				{Name: "com.example:api", Version: "3.0.0"},
+				{Name: "com.example:util", Version: "3.0.0"},
+			},
+		},
+		{
+			name: "dependency management",
+			pom: `<project>
+  <properties>
+    <slf4j.version>2.0.9</slf4j.version>
+  </properties>
+  <dependencyManagement>
+    <dependencies>
+      <dependency>
+        <groupId>org.slf4j</groupId>
+        <artifactId>slf4j-api</artifactId>
+        <version>${slf4j.version}</version>
+      </dependency>
+    </dependencies>
+  </dependencyManagement>
+  <dependencies>
+    <dependency>
+      <groupId>org.slf4j</groupId>
+      <artifactId>slf4j-api</artifactId>
+    </dependency>
+    <dependency>
+      <groupId>org.slf4j</groupId>
+      <artifactId>slf4j-simple</artifactId>
+    </dependency>
+  </dependencies>
+</project>`,
+			want:           []Dependency{{Name: "org.slf4j:slf4j-api", Version: "2.0.9"}},
+			wantUnresolved: []string{"org.slf4j:slf4j-simple"},
+		},
+		{
+			name: "skipped scopes and optional dependencies",
+			pom: `<project>
+  <dependencies>
+    <dependency><groupId>junit</groupId><artifactId>junit</artifactId><version>4.13.2</version><scope>test</scope></dependency>
+    <dependency><groupId>javax.servlet</groupId><artifactId>servlet-api</artifactId><version>2.5</version><scope>provided</scope></dependency>
+    <dependency><groupId>com.sun</groupId><artifactId>tools</artifactId><version>1.8</version><scope>system</scope></dependency>
+    <dependency><groupId>org.example</groupId><artifactId>bom</artifactId><version>1.0</version><scope>import</scope></dependency>
+    <dependency><groupId>org.example</groupId><artifactId>extra</artifactId><version>1.0</version><optional>true</optional></dependency>
+    <dependency><groupId>org.example</groupId><artifactId>runtime</artifactId><version>1.0</version><scope>runtime</scope></dependency>
+    <dependency><groupId>org.example</groupId><artifactId>required</artifactId><version>1.0</version><optional>false</optional></dependency>
+  </dependencies>
+</project>`,
+			want: []Dependency{
+				{Name: "org.example:runtime", Version: "1.0"},
+				{Name: "org.example:required", Version: "1.0"},
+			},
+		},
+		{
+			name: "ranges and unknown properties",
+			pom: `<project>
+  <dependencies>
+    <dependency><groupId>org.example</groupId><artifactId>ranged</artifactId><version>[1.0,2.0)</version></dependency>
+    <dependency><groupId>org.example</groupId><artifactId>unknown</artifactId><version>${unknown.version}</version></dependency>
+    <dependency><groupId>org.example</groupId><artifactId>pinned</artifactId><version>1.0</version></dependency>
+  </dependencies>
+</project>`,
+			want:           []Dependency{{Name: "org.example:pinned", Version: "1.0"}},
+			wantUnresolved: []string{"org.example:ranged@[1.0,2.0)", "org.example:unknown@${unknown.version}"},
+		},
+		{
+			name:    "malformed",
+			pom:     "<project><dependencies>",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unresolved, err := parsePOM([]byte(tt.pom))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePOM() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePOM() = %v, want %v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(unresolved, tt.wantUnresolved) {
+				t.Errorf("parsePOM() unresolved = %v, want %v", unresolved, tt.wantUnresolved)
+			}
+		})
+	}
+}
+
+func TestParseGradleLockfile(t *testing.T) {
+	lockfile := `# This is a Gradle generated file for dependency locking.
+# Manual edits can break the build and are not advised.
+# This file is expected to be part of source control.
+com.google.guava:guava:33.0.0-jre=compileClasspath,runtimeClasspath
+junit:junit:4.13.2=testCompileClasspath,testRuntimeClasspath
+org.mockito:mockito-core:5.8.0=testRuntimeClasspath
+org.slf4j:slf4j-api:2.0.9=runtimeClasspath,testRuntimeClasspath
+org.example:malformed=compileClasspath
+empty=annotationProcessor
+`
+
+	want := []Dependency{
+		{Name: "com.google.guava:guava", Version: "33.0.0-jre"},
+		{Name: "org.slf4j:slf4j-api", Version: "2.0.9"},
+	}
+
+	got, unresolved, err := parseGradleLockfile([]byte(lockfile))
+	if err != nil {
+		t.Fatalf("parseGradleLockfile() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGradleLockfile() = %v, want %v", got, want)
+	}
+
+	if unresolved != nil {
+		t.Errorf("parseGradleLockfile() unresolved = %v, want none", unresolved)
+	}
+}
+
+func TestNonTestConfiguration(t *testing.T) {
+	tests := []struct {
+		configurations string
+		want           bool
+	}{
+		{configurations: "compileClasspath,runtimeClasspath", want: true},
+		{configurations: "testCompileClasspath,testRuntimeClasspath", want: false},
+		{configurations: "runtimeClasspath,testRuntimeClasspath", want: true},
+		{configurations: "TestFixturesRuntimeClasspath", want: false},
+		{configurations: " testRuntimeClasspath , kapt ", want: true},
+		{configurations: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := nonTestConfiguration(tt.configurations); got != tt.want {
+			t.Errorf("nonTestConfiguration(%q) = %v, want %v", tt.configurations, got, tt.want)
+		}
+	}
+}
+
+func TestMavenFetch(t *testing.T) {
+	jar := zipArchive(t, map[string]string{"com/example/Lib.java": "package com.example;\n\npublic class Lib {}\n"})
+	sum := sha1.Sum(jar)
+	other := sha1.Sum([]byte("other"))
+
+	// Every artifact has the same source jar, with a checksum file depending on the version.
+	checksums := map[string]string{
+		"1.0": hex.EncodeToString(sum[:]) + "  lib-1.0-sources.jar\n",
+		"2.0": hex.EncodeToString(other[:]),
+		"4.0": "not a checksum",
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for version, checksum := range checksums {
+			if r.URL.Path == "/maven2/com/example/lib/"+version+"/lib-"+version+"-sources.jar.sha1" {
+				_, _ = w.Write([]byte(checksum))
+				return
+			}
+		}
+
+		for _, version := range []string{"1.0", "2.0", "3.0", "4.0"} {
+			if r.URL.Path == "/maven2/com/example/lib/"+version+"/lib-"+version+"-sources.jar" {
+				_, _ = w.Write(jar)
+				return
+			}
+		}
+
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	m := NewMaven(srv.URL+"/maven2/", srv.Client())
+
+	tests := []struct {
+		name    string
+		dep     Dependency
+		wantErr bool
+	}{
+		{name: "matching checksum", dep: Dependency{Name: "com.example:lib", Version: "1.0"}},
+		{name: "mismatching checksum", dep: Dependency{Name: "com.example:lib", Version: "2.0"}, wantErr: true},
+		{name: "missing checksum", dep: Dependency{Name: "com.example:lib", Version: "3.0"}},
+		{name: "malformed checksum", dep: Dependency{Name: "com.example:lib", Version: "4.0"}, wantErr: true},
+		{name: "missing jar", dep: Dependency{Name: "com.example:lib", Version: "5.0"}, wantErr: true},
+		{name: "invalid coordinates", dep: Dependency{Name: "lib", Version: "1.0"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			err := m.Fetch(context.Background(), tt.dep, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr {
+				readFile(t, dir, "com/example/Lib.java")
+			}
+		})
+	}
+}
+
+func TestMavenChecksum(t *testing.T) {
+	jar := []byte("jar")
+	sum := sha1.Sum(jar)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/plain.sha1":
+			_, _ = w.Write([]byte(hex.EncodeToString(sum[:])))
+		case "/named.sha1":
+			_, _ = w.Write([]byte(hex.EncodeToString(sum[:]) + " *lib.jar\n"))
+		case "/empty.sha1":
+		case "/broken.sha1":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	m := NewMaven(srv.URL, srv.Client())
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "/plain.sha1", want: sha1SRI(jar)},
+		{path: "/named.sha1", want: sha1SRI(jar)},
+		{path: "/empty.sha1", want: ""},
+		{path: "/missing.sha1", want: ""},
+		{path: "/broken.sha1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := m.checksum(context.Background(), srv.URL+tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checksum() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("checksum() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
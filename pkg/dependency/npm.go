@@ -0,0 +1,399 @@
+package dependency
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NPM resolves the dependencies of JavaScript and TypeScript projects, and fetches them from an npm registry.
+type NPM struct {
+	registry string
+	client   *http.Client
+}
+
+// NewNPM creates an NPM resolver for the registry at the given URL, e.g. https://registry.npmjs.org.
+func NewNPM(registry string, client *http.Client) *NPM {
+	return &NPM{
+		registry: strings.TrimSuffix(registry, "/"),
+		client:   client,
+	}
+}
+
+// Languages is a method of the NPM struct. It returns JavaScript and TypeScript.
+func (n *NPM) Languages() []string {
+	return []string{"JavaScript", "TypeScript"}
+}
+
+// Detect is a method of the NPM struct. It reports whether dir contains package.json.
+func (n *NPM) Detect(dir string) bool {
+	return exists(dir, "package.json")
+}
+
+// Dependencies is a method of the NPM struct. It returns the dependencies of the project in dir, pinned to the exact
+// versions of its lockfile. package-lock.json, npm-shrinkwrap.json, yarn.lock and pnpm-lock.yaml are supported, in
+// that order. Without a lockfile, the version ranges of the dependencies in package.json are resolved against the
+// registry, which only covers the direct dependencies. Development dependencies are left out where the lockfile marks
+// them. Dependencies on git repositories, tarball URLs and the like are unresolved.
+func (n *NPM) Dependencies(ctx context.Context, dir string) ([]Dependency, []string, error) {
+	if _, err := os.Stat(filepath.Join(dir, "package.json")); errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	}
+
+	parsers := []struct {
+		name  string
+		parse func([]byte) ([]Dependency, error)
+	}{
+		{"package-lock.json", parsePackageLock},
+		{"npm-shrinkwrap.json", parsePackageLock},
+		{"yarn.lock", parseYarnLock},
+		{"pnpm-lock.yaml", parsePNPMLock},
+	}
+
+	for _, p := range parsers {
+		b, err := os.ReadFile(filepath.Join(dir, p.name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		locked, err := p.parse(b)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to parse %v: %v", p.name, err)
+		}
+
+		var (
+			deps       []Dependency
+			unresolved []string
+		)
+
+		for _, dep := range unique(locked) {
+			if registryVersion(dep.Version) {
+				deps = append(deps, dep)
+			} else {
+				unresolved = append(unresolved, dep.String())
+			}
+		}
+
+		return deps, unresolved, nil
+	}
+
+	return n.resolvePackageJSON(ctx, dir)
+}
+
+// Fetch is a method of the NPM struct. It downloads the package archive of a dependency from the registry, verifies it
+// against the integrity of the lockfile if known, and extracts it into dir.
+func (n *NPM) Fetch(ctx context.Context, dep Dependency, dir string) error {
+	// Scoped packages are stored under their full name, but their archives are named without the scope.
+	base := dep.Name[strings.LastIndex(dep.Name, "/")+1:]
+	u := fmt.Sprintf("%s/%s/-/%s-%s.tgz", n.registry, dep.Name, base, dep.Version)
+
+	body, err := get(ctx, n.client, u, "")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	if err = extractVerified(body, dep.Integrity, dir, extractTarGz); err != nil {
+		return fmt.Errorf("unable to extract %v: %v", dep, err)
+	}
+
+	return nil
+}
+
+// resolvePackageJSON is a method of the NPM struct. It resolves the version ranges of the dependencies of package.json
+// to the highest matching version published to the registry. Dependencies on anything but a registry version, e.g.
+// git repositories or local paths, and ranges no published version satisfies are unresolved.
+func (n *NPM) resolvePackageJSON(ctx context.Context, dir string) ([]Dependency, []string, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var manifest struct {
+		Dependencies map[string]string `json:"dependencies"`
+	}
+
+	if err = json.Unmarshal(b, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("unable to parse package.json: %v", err)
+	}
+
+	var (
+		deps       []Dependency
+		unresolved []string
+		errs       []error
+	)
+
+	for _, name := range slices.Sorted(maps.Keys(manifest.Dependencies)) {
+		spec := manifest.Dependencies[name]
+		text := name + "@" + spec
+
+		// An alias "npm:other@range" installs another package under the name.
+		if alias, ok := strings.CutPrefix(spec, "npm:"); ok {
+			at := strings.LastIndex(alias, "@")
+			if at <= 0 {
+				unresolved = append(unresolved, text)
+				continue
+			}
+
+			name, spec = alias[:at], alias[at+1:]
+		}
+
+		r, err := parseNPMRange(spec)
+		if err != nil {
+			unresolved = append(unresolved, text)
+			continue
+		}
+
+		versions, err := n.versions(ctx, name)
+		if err != nil {
+			unresolved = append(unresolved, text)
+
+			// A registry that cannot be reached is an error, a package it does not know is not.
+			if !errors.Is(err, errNotFound) {
+				errs = append(errs, err)
+			}
+
+			continue
+		}
+
+		version, ok := r.Match(versions)
+		if !ok {
+			unresolved = append(unresolved, text)
+			continue
+		}
+
+		deps = append(deps, Dependency{Name: name, Version: version})
+	}
+
+	return deps, unresolved, errors.Join(errs...)
+}
+
+// versions is a method of the NPM struct. It returns every version of a package published to the registry.
+func (n *NPM) versions(ctx context.Context, name string) ([]string, error) {
+	// Scoped names keep their "@", but the slash is escaped.
+	body, err := get(ctx, n.client, n.registry+"/"+strings.Replace(url.PathEscape(name), "%40", "@", 1), "application/vnd.npm.install-v1+json")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = body.Close() }()
+
+	var packument struct {
+		Versions map[string]json.RawMessage `json:"versions"`
+	}
+
+	if err = json.NewDecoder(body).Decode(&packument); err != nil {
+		return nil, fmt.Errorf("unable to decode the metadata of %v: %v", name, err)
+	}
+
+	return slices.Collect(maps.Keys(packument.Versions)), nil
+}
+
+type packageLockDependency struct {
+	Version      string                           `json:"version"`
+	Integrity    string                           `json:"integrity"`
+	Dev          bool                             `json:"dev"`
+	Dependencies map[string]packageLockDependency `json:"dependencies"`
+}
+
+// parsePackageLock parses package-lock.json and npm-shrinkwrap.json. Version 2 and 3 lockfiles list every installed
+// package under "packages", keyed by its path in node_modules; version 1 lockfiles nest them under "dependencies".
+func parsePackageLock(b []byte) ([]Dependency, error) {
+	var lock struct {
+		Packages map[string]struct {
+			Name      string `json:"name"`
+			Version   string `json:"version"`
+			Integrity string `json:"integrity"`
+			Dev       bool   `json:"dev"`
+			Link      bool   `json:"link"`
+		} `json:"packages"`
+		Dependencies map[string]packageLockDependency `json:"dependencies"`
+	}
+
+	if err := json.Unmarshal(b, &lock); err != nil {
+		return nil, err
+	}
+
+	var deps []Dependency
+
+	if lock.Packages != nil {
+		for path, p := range lock.Packages {
+			// The empty path is the project itself, links are workspaces of the project.
+			i := strings.LastIndex(path, "node_modules/")
+			if i < 0 || p.Dev || p.Link {
+				continue
+			}
+
+			name := p.Name
+			if name == "" {
+				name = path[i+len("node_modules/"):]
+			}
+
+			deps = append(deps, Dependency{Name: name, Version: p.Version, Integrity: p.Integrity})
+		}
+
+		return deps, nil
+	}
+
+	var walk func(map[string]packageLockDependency)
+	walk = func(dependencies map[string]packageLockDependency) {
+		for name, d := range dependencies {
+			if d.Dev {
+				continue
+			}
+
+			deps = append(deps, Dependency{Name: name, Version: d.Version, Integrity: d.Integrity})
+
+			walk(d.Dependencies)
+		}
+	}
+
+	walk(lock.Dependencies)
+
+	return deps, nil
+}
+
+// parseYarnLock parses the yarn.lock of Yarn 1, and the YAML based one of later versions. Every entry starts with an
+// unindented line of the specs it resolves, followed by indented fields.
+func parseYarnLock(b []byte) ([]Dependency, error) {
+	var (
+		deps    []Dependency
+		current *Dependency
+	)
+
+	flush := func() {
+		if current != nil {
+			deps = append(deps, *current)
+		}
+
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if !strings.HasPrefix(line, " ") {
+			flush()
+
+			if name, ok := yarnEntryName(strings.TrimSuffix(trimmed, ":")); ok {
+				current = &Dependency{Name: name}
+			}
+
+			continue
+		}
+
+		// Only the fields of the entry itself matter, not the nested dependency lists.
+		if current == nil || strings.HasPrefix(line, "    ") {
+			continue
+		}
+
+		key, value, _ := strings.Cut(trimmed, " ")
+		key = strings.TrimSuffix(key, ":")
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+
+		switch key {
+		case "version":
+			current.Version = value
+		case "integrity":
+			current.Integrity = value
+		}
+	}
+
+	flush()
+
+	return deps, scanner.Err()
+}
+
+// yarnEntryName returns the package name of a yarn.lock entry header such as `"@babel/core@^7.0.0", "@babel/core@^7.1"`
+// or `"lodash@npm:^4.17.21"`. Entries resolved by anything but the registry, and the metadata entry, are skipped.
+func yarnEntryName(header string) (string, bool) {
+	spec, _, _ := strings.Cut(header, ",")
+	spec = strings.Trim(strings.TrimSpace(spec), `"`)
+
+	at := strings.Index(spec[min(1, len(spec)):], "@") + min(1, len(spec))
+	if at <= 0 || at >= len(spec) {
+		return "", false
+	}
+
+	name, rangeSpec := spec[:at], spec[at+1:]
+
+	if protocol, _, ok := strings.Cut(rangeSpec, ":"); ok && protocol != "npm" {
+		return "", false
+	}
+
+	return name, true
+}
+
+// parsePNPMLock parses pnpm-lock.yaml. Packages are keyed by "/name/version" up to version 5 of the lockfile, by
+// "/name@version" in version 6 and by "name@version" since version 9, possibly followed by the versions of their peer
+// dependencies.
+func parsePNPMLock(b []byte) ([]Dependency, error) {
+	var lock struct {
+		LockfileVersion any `yaml:"lockfileVersion"`
+		Packages        map[string]struct {
+			Resolution struct {
+				Integrity string `yaml:"integrity"`
+			} `yaml:"resolution"`
+			Dev bool `yaml:"dev"`
+		} `yaml:"packages"`
+	}
+
+	if err := yaml.Unmarshal(b, &lock); err != nil {
+		return nil, err
+	}
+
+	legacy := strings.HasPrefix(fmt.Sprint(lock.LockfileVersion), "5")
+
+	var deps []Dependency
+
+	for key, p := range lock.Packages {
+		if p.Dev {
+			continue
+		}
+
+		key, _, _ = strings.Cut(strings.TrimPrefix(key, "/"), "(")
+
+		sep := strings.LastIndex(key, "@")
+		if legacy {
+			key, _, _ = strings.Cut(key, "_")
+			sep = strings.LastIndex(key, "/")
+		}
+
+		if sep <= 0 {
+			continue
+		}
+
+		deps = append(deps, Dependency{Name: key[:sep], Version: key[sep+1:], Integrity: p.Resolution.Integrity})
+	}
+
+	return deps, nil
+}
+
+// registryVersion reports whether a locked version is a plain version published to a registry, rather than e.g. a
+// git commit, a tarball URL or a local path.
+func registryVersion(version string) bool {
+	_, _, _, parts, _, err := parsePartial(version)
+	return err == nil && parts == 3
+}
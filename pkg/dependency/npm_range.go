@@ -0,0 +1,248 @@
+package dependency
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// npmRange is a parsed npm version range (https://docs.npmjs.com/cli/v10/configuring-npm/package-json#dependencies),
+// a union of comparator sets that each have to be satisfied in full.
+type npmRange [][]comparator
+
+type comparator struct {
+	op      string
+	version string
+}
+
+// parseNPMRange parses the range syntax of npm: comparators, x-ranges, tilde, caret and hyphen ranges, combined with
+// spaces and "||".
+func parseNPMRange(s string) (npmRange, error) {
+	var r npmRange
+
+	for _, set := range strings.Split(s, "||") {
+		fields := strings.Fields(set)
+
+		// A hyphen range "1.2.3 - 2.3.4" includes both ends.
+		if len(fields) == 3 && fields[1] == "-" {
+			lower, err := expand(">=", fields[0])
+			if err != nil {
+				return nil, err
+			}
+
+			upper, err := expand("<=", fields[2])
+			if err != nil {
+				return nil, err
+			}
+
+			r = append(r, append(lower, upper...))
+			continue
+		}
+
+		var comparators []comparator
+
+		// Operators may be separated from their version by spaces, e.g. ">= 1.2.3".
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			if isOperator(field) && i+1 < len(fields) {
+				i++
+				field += fields[i]
+			}
+
+			c, err := parseComparator(field)
+			if err != nil {
+				return nil, err
+			}
+
+			comparators = append(comparators, c...)
+		}
+
+		r = append(r, comparators)
+	}
+
+	return r, nil
+}
+
+// Match is a method of the npmRange type. It returns the highest of the given versions that satisfies the range.
+// Prereleases are only matched by a range naming them exactly.
+func (r npmRange) Match(versions []string) (string, bool) {
+	best := ""
+
+	for _, version := range versions {
+		v := "v" + strings.TrimPrefix(version, "v")
+		if !semver.IsValid(v) || !r.satisfies(v) {
+			continue
+		}
+
+		if best == "" || semver.Compare(v, "v"+best) > 0 {
+			best = strings.TrimPrefix(v, "v")
+		}
+	}
+
+	return best, best != ""
+}
+
+func (r npmRange) satisfies(v string) bool {
+	for _, set := range r {
+		if satisfiesAll(set, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func satisfiesAll(set []comparator, v string) bool {
+	for _, c := range set {
+		if semver.Prerelease(v) != "" && c.op != "=" {
+			return false
+		}
+
+		cmp := semver.Compare(v, c.version)
+
+		switch {
+		case c.op == "=" && cmp != 0,
+			c.op == ">" && cmp <= 0,
+			c.op == ">=" && cmp < 0,
+			c.op == "<" && cmp >= 0,
+			c.op == "<=" && cmp > 0:
+			return false
+		}
+	}
+
+	return true
+}
+
+func isOperator(s string) bool {
+	switch s {
+	case "<", "<=", ">", ">=", "=", "~", "^":
+		return true
+	default:
+		return false
+	}
+}
+
+func parseComparator(s string) ([]comparator, error) {
+	for _, op := range []string{"<=", ">=", "<", ">", "=", "~", "^"} {
+		if rest, ok := strings.CutPrefix(s, op); ok {
+			return expand(op, rest)
+		}
+	}
+
+	return expand("", s)
+}
+
+// expand translates a single operator and a possibly partial version into plain comparators.
+func expand(op string, s string) ([]comparator, error) {
+	major, minor, patch, parts, pre, err := parsePartial(s)
+	if err != nil {
+		return nil, err
+	}
+
+	v := func(major, minor, patch int, pre string) string {
+		version := fmt.Sprintf("v%d.%d.%d", major, minor, patch)
+		if pre != "" {
+			version += "-" + pre
+		}
+
+		return version
+	}
+
+	lower := v(major, minor, patch, pre)
+
+	// next is the lowest version above every version matching the partial version.
+	var next string
+
+	switch parts {
+	case 0:
+		if op == "<" || op == ">" {
+			return []comparator{{"<", "v0.0.0-0"}}, nil
+		}
+
+		return nil, nil
+	case 1:
+		next = v(major+1, 0, 0, "")
+	case 2:
+		next = v(major, minor+1, 0, "")
+	default:
+		next = v(major, minor, patch+1, "")
+	}
+
+	switch op {
+	case "", "=":
+		if parts == 3 {
+			return []comparator{{"=", lower}}, nil
+		}
+
+		return []comparator{{">=", lower}, {"<", next}}, nil
+	case ">":
+		if parts == 3 {
+			return []comparator{{">", lower}}, nil
+		}
+
+		return []comparator{{">=", next}}, nil
+	case ">=":
+		return []comparator{{">=", lower}}, nil
+	case "<":
+		return []comparator{{"<", lower}}, nil
+	case "<=":
+		if parts == 3 {
+			return []comparator{{"<=", lower}}, nil
+		}
+
+		return []comparator{{"<", next}}, nil
+	case "~":
+		if parts == 1 {
+			return []comparator{{">=", lower}, {"<", v(major+1, 0, 0, "")}}, nil
+		}
+
+		return []comparator{{">=", lower}, {"<", v(major, minor+1, 0, "")}}, nil
+	case "^":
+		switch {
+		case major > 0 || parts == 1:
+			return []comparator{{">=", lower}, {"<", v(major+1, 0, 0, "")}}, nil
+		case minor > 0 || parts == 2:
+			return []comparator{{">=", lower}, {"<", v(0, minor+1, 0, "")}}, nil
+		default:
+			return []comparator{{">=", lower}, {"<", v(0, 0, patch+1, "")}}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid version range operator %q", op)
+}
+
+// parsePartial parses a version that may leave out, or wildcard, its minor and patch parts. It returns how many parts
+// were given.
+func parsePartial(s string) (major, minor, patch, parts int, pre string, err error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+
+	// Build metadata never affects the precedence of a version.
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, _ = strings.Cut(s, "-")
+
+	if s == "" {
+		return 0, 0, 0, 0, "", nil
+	}
+
+	numbers := []*int{&major, &minor, &patch}
+
+	for i, part := range strings.Split(s, ".") {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+
+		if i >= len(numbers) {
+			return 0, 0, 0, 0, "", fmt.Errorf("invalid version %q", s)
+		}
+
+		if *numbers[i], err = strconv.Atoi(part); err != nil {
+			return 0, 0, 0, 0, "", fmt.Errorf("invalid version %q", s)
+		}
+
+		parts++
+	}
+
+	return major, minor, patch, parts, pre, nil
+}
@@ -0,0 +1,90 @@
+package dependency
+
+import "testing"
+
+func TestNPMRangeMatch(t *testing.T) {
+	versions := []string{
+		"0.0.1", "0.0.2", "0.1.0", "0.1.5", "0.2.0",
+		"1.0.0", "1.2.3", "1.2.9", "1.3.0", "1.9.9",
+		"2.0.0-beta.1", "2.0.0", "2.1.0", "3.0.0",
+	}
+
+	tests := []struct {
+		rangeSpec string
+		want      string
+		wantOK    bool
+	}{
+		{rangeSpec: "1.2.3", want: "1.2.3", wantOK: true},
+		{rangeSpec: "=1.2.3", want: "1.2.3", wantOK: true},
+		{rangeSpec: "v1.2.3", want: "1.2.3", wantOK: true},
+		{rangeSpec: "^1.2.3", want: "1.9.9", wantOK: true},
+		{rangeSpec: "~1.2.3", want: "1.2.9", wantOK: true},
+		{rangeSpec: "~1", want: "1.9.9", wantOK: true},
+		{rangeSpec: "^0.1.0", want: "0.1.5", wantOK: true},
+		{rangeSpec: "^0.0.1", want: "0.0.1", wantOK: true},
+		{rangeSpec: "^0", want: "0.2.0", wantOK: true},
+		{rangeSpec: "1.2.x", want: "1.2.9", wantOK: true},
+		{rangeSpec: "1.x", want: "1.9.9", wantOK: true},
+		{rangeSpec: "1", want: "1.9.9", wantOK: true},
+		{rangeSpec: "*", want: "3.0.0", wantOK: true},
+		{rangeSpec: "", want: "3.0.0", wantOK: true},
+		{rangeSpec: ">=1.2.3 <2.0.0", want: "1.9.9", wantOK: true},
+		{rangeSpec: ">= 1.2.3 < 2", want: "1.9.9", wantOK: true},
+		{rangeSpec: ">1", want: "3.0.0", wantOK: true},
+		{rangeSpec: ">1.9.9 <3", want: "2.1.0", wantOK: true},
+		{rangeSpec: "<=1.2", want: "1.2.9", wantOK: true},
+		{rangeSpec: "<1.0.0", want: "0.2.0", wantOK: true},
+		{rangeSpec: "1.2.3 - 2.0.0", want: "2.0.0", wantOK: true},
+		{rangeSpec: "1.2 - 2", want: "2.1.0", wantOK: true},
+		{rangeSpec: "<1.0.0 || >=3", want: "3.0.0", wantOK: true},
+		{rangeSpec: "^0.0.1 || ~1.2.0", want: "1.2.9", wantOK: true},
+		{rangeSpec: "=2.0.0-beta.1", want: "2.0.0-beta.1", wantOK: true},
+		{rangeSpec: ">=2.0.0-beta.0 <2.0.0", wantOK: false},
+		{rangeSpec: "^4.0.0", wantOK: false},
+		{rangeSpec: "<0", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rangeSpec, func(t *testing.T) {
+			r, err := parseNPMRange(tt.rangeSpec)
+			if err != nil {
+				t.Fatalf("parseNPMRange(%q) error = %v", tt.rangeSpec, err)
+			}
+
+			got, ok := r.Match(versions)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Match() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseNPMRangeInvalid(t *testing.T) {
+	for _, rangeSpec := range []string{"latest", "1.2.3.4", "github:user/repo", "file:../lib", "^a.b"} {
+		t.Run(rangeSpec, func(t *testing.T) {
+			if _, err := parseNPMRange(rangeSpec); err == nil {
+				t.Errorf("parseNPMRange(%q) succeeded, want an error", rangeSpec)
+			}
+		})
+	}
+}
+
+func TestRegistryVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{version: "1.2.3", want: true},
+		{version: "1.2.3-beta.1", want: true},
+		{version: "1.2", want: false},
+		{version: "git+ssh://git@github.com/user/repo.git#abc123", want: false},
+		{version: "file:../lib", want: false},
+		{version: "https://example.com/pkg.tgz", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := registryVersion(tt.version); got != tt.want {
+			t.Errorf("registryVersion(%q) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
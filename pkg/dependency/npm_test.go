@@ -0,0 +1,429 @@
+package dependency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParsePackageLock(t *testing.T) {
+	tests := []struct {
+		name string
+		lock string
+		want []Dependency
+	}{
+		{
+			name: "version 1",
+			lock: `{
+				"lockfileVersion": 1,
+				"dependencies": {
+					"a": {
+						"version": "1.0.0",
+						"integrity": "sha512-a",
+						"dependencies": {
+							"b": {"version": "2.0.0", "integrity": "sha512-b"}
+						}
+					},
+					"@scope/c": {"version": "3.0.0"},
+					"d": {"version": "1.0.0", "dev": true, "dependencies": {"e": {"version": "1.0.0"}}}
+				}
+			}`,
+			want: []Dependency{
+				{Name: "@scope/c", Version: "3.0.0"},
+				{Name: "a", Version: "1.0.0", Integrity: "sha512-a"},
+				{Name: "b", Version: "2.0.0", Integrity: "sha512-b"},
+			},
+		},
+		{
+			name: "version 2 prefers packages",
+			lock: `{
+				"lockfileVersion": 2,
+				"packages": {
+					"": {"name": "app", "dependencies": {"a": "^1.0.0"}},
+					"node_modules/a": {"version": "1.0.1", "integrity": "sha512-a"}
+				},
+				"dependencies": {
+					"a": {"version": "1.0.0", "integrity": "sha512-old"}
+				}
+			}`,
+			want: []Dependency{
+				{Name: "a", Version: "1.0.1", Integrity: "sha512-a"},
+			},
+		},
+		{
+			name: "version 3",
+			lock: `{
+				"lockfileVersion": 3,
+				"packages": {
+					"": {"name": "app"},
+					"node_modules/a": {"version": "1.0.0", "integrity": "sha512-a"},
+					"node_modules/a/node_modules/b": {"version": "2.0.0"},
+					"node_modules/@scope/c": {"version": "3.0.0"},
+					"node_modules/d": {"version": "1.0.0", "dev": true},
+					"node_modules/workspace": {"resolved": "packages/workspace", "link": true},
+					"packages/workspace": {"version": "0.1.0"},
+					"node_modules/alias": {"name": "real", "version": "4.0.0"}
+				}
+			}`,
+			want: []Dependency{
+				{Name: "@scope/c", Version: "3.0.0"},
+				{Name: "a", Version: "1.0.0", Integrity: "sha512-a"},
+				{Name: "b", Version: "2.0.0"},
+				{Name: "real", Version: "4.0.0"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePackageLock([]byte(tt.lock))
+			if err != nil {
+				t.Fatalf("parsePackageLock() error = %v", err)
+			}
+
+			if got = unique(got); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePackageLock() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYarnLock(t *testing.T) {
+	tests := []struct {
+		name string
+		lock string
+		want []Dependency
+	}{
+		{
+			name: "yarn 1",
+			lock: `# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY.
+# yarn lockfile v1
+
+
+"@babel/code-frame@^7.0.0", "@babel/code-frame@^7.10.4":
+  version "7.12.13"
+  resolved "https://registry.yarnpkg.com/@babel/code-frame/-/code-frame-7.12.13.tgz#dcfc826b"
+  integrity sha512-babel
+  dependencies:
+    "@babel/highlight" "^7.12.13"
+
+lodash@^4.17.20, lodash@^4.17.21:
+  version "4.17.21"
+  integrity sha512-lodash
+
+"fork@git+https://github.com/user/fork.git":
+  version "1.0.0"
+`,
+			want: []Dependency{
+				{Name: "@babel/code-frame", Version: "7.12.13", Integrity: "sha512-babel"},
+				{Name: "lodash", Version: "4.17.21", Integrity: "sha512-lodash"},
+			},
+		},
+		{
+			name: "yarn 2 and later",
+			lock: `# This file is generated by running "yarn install" inside your project.
+
+__metadata:
+  version: 6
+  cacheKey: 8
+
+"app@workspace:.":
+  version: 0.0.0-use.local
+  resolution: "app@workspace:."
+  languageName: unknown
+  linkType: soft
+
+"lodash@npm:^4.17.21":
+  version: 4.17.21
+  resolution: "lodash@npm:4.17.21"
+  checksum: eb835a2e51d381e561e508ce932ea50a8e5a68f4ebdd771ea240d3048244a8d13658acbd502cd4829768c56f2e16bdd4340b9ea141297d472517b83868e677f7
+  languageName: node
+  linkType: hard
+
+"@types/node@npm:*, @types/node@npm:^20.0.0":
+  version: 20.1.0
+  dependencies:
+    undici-types: "npm:~5.26.4"
+`,
+			want: []Dependency{
+				{Name: "@types/node", Version: "20.1.0"},
+				{Name: "lodash", Version: "4.17.21"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYarnLock([]byte(tt.lock))
+			if err != nil {
+				t.Fatalf("parseYarnLock() error = %v", err)
+			}
+
+			if got = unique(got); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseYarnLock() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePNPMLock(t *testing.T) {
+	want := []Dependency{
+		{Name: "@babel/core", Version: "7.0.0", Integrity: "sha512-babel"},
+		{Name: "lodash", Version: "4.17.21", Integrity: "sha512-lodash"},
+	}
+
+	tests := []struct {
+		name string
+		lock string
+	}{
+		{
+			name: "version 5",
+			lock: `lockfileVersion: 5.4
+packages:
+  /lodash/4.17.21:
+    resolution: {integrity: sha512-lodash}
+    dev: false
+  /@babel/core/7.0.0_react@18.0.0:
+    resolution: {integrity: sha512-babel}
+  /jest/29.0.0:
+    resolution: {integrity: sha512-jest}
+    dev: true
+`,
+		},
+		{
+			name: "version 6",
+			lock: `lockfileVersion: '6.0'
+packages:
+  /lodash@4.17.21:
+    resolution: {integrity: sha512-lodash}
+    dev: false
+  /@babel/core@7.0.0(react@18.0.0):
+    resolution: {integrity: sha512-babel}
+  /jest@29.0.0:
+    resolution: {integrity: sha512-jest}
+    dev: true
+`,
+		},
+		{
+			name: "version 9",
+			lock: `lockfileVersion: '9.0'
+packages:
+  lodash@4.17.21:
+    resolution: {integrity: sha512-lodash}
+  '@babel/core@7.0.0':
+    resolution: {integrity: sha512-babel}
+snapshots:
+  lodash@4.17.21: {}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePNPMLock([]byte(tt.lock))
+			if err != nil {
+				t.Fatalf("parsePNPMLock() error = %v", err)
+			}
+
+			if got = unique(got); !reflect.DeepEqual(got, want) {
+				t.Errorf("parsePNPMLock() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// npmRegistry serves the packuments and archives of the given packages like an npm registry, e.g. Verdaccio. Any
+// other path is not found, and a package named "broken" fails.
+func npmRegistry(t *testing.T, packuments map[string]string, archives map[string][]byte) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.EscapedPath()
+
+		if path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if packument, ok := packuments[path]; ok {
+			if accept := r.Header.Get("Accept"); accept != "application/vnd.npm.install-v1+json" {
+				t.Errorf("Accept = %q, want the abbreviated metadata", accept)
+			}
+
+			_, _ = w.Write([]byte(packument))
+			return
+		}
+
+		if archive, ok := archives[path]; ok {
+			_, _ = w.Write(archive)
+			return
+		}
+
+		http.NotFound(w, r)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestNPMDependencies(t *testing.T) {
+	srv := npmRegistry(t, map[string]string{
+		"/left-pad":     `{"name": "left-pad", "versions": {"1.0.0": {}, "1.1.0": {}, "1.3.0": {}, "2.0.0": {}}}`,
+		"/@scope%2Fpkg": `{"name": "@scope/pkg", "versions": {"2.0.0": {}, "2.0.1": {}, "2.1.0": {}}}`,
+	}, nil)
+
+	n := NewNPM(srv.URL+"/", srv.Client())
+
+	tests := []struct {
+		name           string
+		files          map[string]string
+		want           []Dependency
+		wantUnresolved []string
+		wantErr        bool
+	}{
+		{
+			name:  "no package.json",
+			files: map[string]string{"index.js": ""},
+		},
+		{
+			name: "lockfile",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"a": "^1.0.0", "fork": "github:user/fork"}}`,
+				"package-lock.json": `{"lockfileVersion": 3, "packages": {
+					"node_modules/a": {"version": "1.0.0", "integrity": "sha512-a"},
+					"node_modules/fork": {"version": "git+ssh://git@github.com/user/fork.git#abc123"},
+					"node_modules/nested/node_modules/a": {"version": "1.0.0", "integrity": "sha512-a"}
+				}}`,
+				"yarn.lock": "b@^1.0.0:\n  version \"1.0.0\"\n",
+			},
+			want:           []Dependency{{Name: "a", Version: "1.0.0", Integrity: "sha512-a"}},
+			wantUnresolved: []string{"fork@git+ssh://git@github.com/user/fork.git#abc123"},
+		},
+		{
+			name: "yarn.lock",
+			files: map[string]string{
+				"package.json": `{}`,
+				"yarn.lock":    "b@^1.0.0:\n  version \"1.0.0\"\n",
+			},
+			want: []Dependency{{Name: "b", Version: "1.0.0"}},
+		},
+		{
+			name: "package.json without a lockfile",
+			files: map[string]string{
+				"package.json": `{
+					"dependencies": {
+						"left-pad": "^1.1.0",
+						"@scope/pkg": "~2.0.0",
+						"alias": "npm:left-pad@^2",
+						"missing": "^1.0.0",
+						"fork": "github:user/fork",
+						"unsatisfiable": "npm:left-pad@^9"
+					},
+					"devDependencies": {"jest": "^29.0.0"}
+				}`,
+			},
+			want: []Dependency{
+				{Name: "@scope/pkg", Version: "2.0.1"},
+				{Name: "left-pad", Version: "2.0.0"},
+				{Name: "left-pad", Version: "1.3.0"},
+			},
+			wantUnresolved: []string{"fork@github:user/fork", "missing@^1.0.0", "unsatisfiable@npm:left-pad@^9"},
+		},
+		{
+			name:           "unreachable registry",
+			files:          map[string]string{"package.json": `{"dependencies": {"broken": "^1.0.0"}}`},
+			wantUnresolved: []string{"broken@^1.0.0"},
+			wantErr:        true,
+		},
+		{
+			name: "malformed lockfile",
+			files: map[string]string{
+				"package.json":      `{}`,
+				"package-lock.json": `{`,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unresolved, err := n.Dependencies(context.Background(), writeFiles(t, tt.files))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Dependencies() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Dependencies() = %v, want %v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(unresolved, tt.wantUnresolved) {
+				t.Errorf("Dependencies() unresolved = %v, want %v", unresolved, tt.wantUnresolved)
+			}
+		})
+	}
+}
+
+func TestNPMFetch(t *testing.T) {
+	leftPad := tarGz(t, map[string]string{"package/index.js": "module.exports = leftPad\n"})
+	scoped := tarGz(t, map[string]string{"package/lib/index.js": "export default 1\n"})
+
+	srv := npmRegistry(t, nil, map[string][]byte{
+		"/left-pad/-/left-pad-1.3.0.tgz": leftPad,
+		"/@scope/pkg/-/pkg-2.0.1.tgz":    scoped,
+	})
+
+	n := NewNPM(srv.URL, srv.Client())
+
+	tests := []struct {
+		name     string
+		dep      Dependency
+		wantFile string
+		wantErr  bool
+		notFound bool
+	}{
+		{
+			name:     "verified",
+			dep:      Dependency{Name: "left-pad", Version: "1.3.0", Integrity: sha512SRI(leftPad)},
+			wantFile: "package/index.js",
+		},
+		{
+			name:     "scoped without integrity",
+			dep:      Dependency{Name: "@scope/pkg", Version: "2.0.1"},
+			wantFile: "package/lib/index.js",
+		},
+		{
+			name:    "integrity mismatch",
+			dep:     Dependency{Name: "left-pad", Version: "1.3.0", Integrity: sha512SRI(scoped)},
+			wantErr: true,
+		},
+		{
+			name:     "missing version",
+			dep:      Dependency{Name: "left-pad", Version: "9.9.9"},
+			wantErr:  true,
+			notFound: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			err := n.Fetch(context.Background(), tt.dep, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if errors.Is(err, errNotFound) != tt.notFound {
+				t.Errorf("Fetch() error = %v, want not found %v", err, tt.notFound)
+			}
+
+			if tt.wantFile != "" {
+				readFile(t, dir, tt.wantFile)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package dependency
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// pep440Pattern matches the versions of Python packages (https://peps.python.org/pep-0440/), including the
+// alternative spellings the specification normalises.
+var pep440Pattern = regexp.MustCompile(`^v?(?:(\d+)!)?(\d+(?:\.\d+)*)` +
+	`(?:[-_.]?(a|b|c|rc|alpha|beta|pre|preview)[-_.]?(\d*))?` +
+	`(?:-(\d+)|([-_.]?(?:post|rev|r)[-_.]?)(\d*))?` +
+	`(?:([-_.]?dev[-_.]?)(\d*))?` +
+	`(?:\+[a-z0-9]+(?:[-_.][a-z0-9]+)*)?$`)
+
+// pep440 is a parsed version of a Python package. Local version labels are ignored.
+type pep440 struct {
+	epoch   int
+	release []int
+
+	// pre is the rank of the prerelease phase, 0 to 2 for alpha, beta and release candidate, and preN its number.
+	// Versions without a prerelease rank above every prerelease, or below them if they are development releases.
+	pre  int
+	preN int
+	post int
+	dev  int
+}
+
+const (
+	noPre  = 3
+	noPost = -1
+	noDev  = math.MaxInt
+)
+
+func parsePEP440(s string) (pep440, error) {
+	m := pep440Pattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return pep440{}, fmt.Errorf("invalid version %q", s)
+	}
+
+	v := pep440{pre: noPre, post: noPost, dev: noDev}
+	v.epoch, _ = strconv.Atoi(m[1])
+
+	for _, part := range strings.Split(m[2], ".") {
+		n, _ := strconv.Atoi(part)
+		v.release = append(v.release, n)
+	}
+
+	if m[3] != "" {
+		switch m[3] {
+		case "a", "alpha":
+			v.pre = 0
+		case "b", "beta":
+			v.pre = 1
+		default:
+			v.pre = 2
+		}
+
+		v.preN, _ = strconv.Atoi(m[4])
+	}
+
+	if m[5] != "" || m[6] != "" {
+		v.post, _ = strconv.Atoi(m[5] + m[7])
+	}
+
+	if m[8] != "" {
+		v.dev, _ = strconv.Atoi(m[9])
+
+		// A development release of a final release comes before its prereleases.
+		if v.pre == noPre && v.post == noPost {
+			v.pre = -1
+		}
+	}
+
+	return v, nil
+}
+
+func (v pep440) prerelease() bool {
+	return v.pre != noPre || v.dev != noDev
+}
+
+func (v pep440) compare(o pep440) int {
+	if c := cmp.Compare(v.epoch, o.epoch); c != 0 {
+		return c
+	}
+
+	if c := compareRelease(v.release, o.release); c != 0 {
+		return c
+	}
+
+	if c := cmp.Compare(v.pre, o.pre); c != 0 {
+		return c
+	}
+
+	if c := cmp.Compare(v.preN, o.preN); c != 0 {
+		return c
+	}
+
+	if c := cmp.Compare(v.post, o.post); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(v.dev, o.dev)
+}
+
+// compareRelease compares release segments, padding the shorter one with zeros, so 1.0 equals 1.0.0.
+func compareRelease(a, b []int) int {
+	for i := range max(len(a), len(b)) {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+
+		if c := cmp.Compare(x, y); c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// pep440Specifier is a parsed version specifier of a Python requirement, e.g. ">=1.2,<2", whose clauses have to be
+// satisfied in full.
+type pep440Specifier []clause
+
+type clause struct {
+	op      string
+	version pep440
+	text    string
+
+	// prefix is set for "==1.2.*" and "!=1.2.*", which match every version starting with the release segments.
+	prefix bool
+}
+
+// parsePEP440Specifier parses a comma separated list of clauses with the operators of PEP 440. An empty specifier
+// matches every version.
+func parsePEP440Specifier(s string) (pep440Specifier, error) {
+	var spec pep440Specifier
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var c clause
+
+		for _, op := range []string{"===", "~=", "==", "!=", "<=", ">=", "<", ">"} {
+			if rest, ok := strings.CutPrefix(part, op); ok {
+				c.op, c.text = op, strings.TrimSpace(rest)
+				break
+			}
+		}
+
+		if c.op == "" {
+			return nil, fmt.Errorf("invalid version specifier %q", part)
+		}
+
+		if c.op == "===" {
+			spec = append(spec, c)
+			continue
+		}
+
+		version := c.text
+		if c.op == "==" || c.op == "!=" {
+			version, c.prefix = strings.CutSuffix(version, ".*")
+		}
+
+		v, err := parsePEP440(version)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.op == "~=" && len(v.release) < 2 {
+			return nil, fmt.Errorf("invalid version specifier %q", part)
+		}
+
+		c.version = v
+		spec = append(spec, c)
+	}
+
+	return spec, nil
+}
+
+// Match is a method of the pep440Specifier type. It returns the highest of the given versions that satisfies the
+// specifier. Prereleases are only matched if a clause names one, or if no final release matches.
+func (s pep440Specifier) Match(versions []string) (string, bool) {
+	type candidate struct {
+		text    string
+		version pep440
+	}
+
+	var finals, prereleases []candidate
+
+	for _, text := range versions {
+		v, err := parsePEP440(text)
+		if err != nil || !s.satisfies(text, v) {
+			continue
+		}
+
+		if v.prerelease() {
+			prereleases = append(prereleases, candidate{text, v})
+		} else {
+			finals = append(finals, candidate{text, v})
+		}
+	}
+
+	if s.namesPrerelease() || len(finals) == 0 {
+		finals = append(finals, prereleases...)
+	}
+
+	if len(finals) == 0 {
+		return "", false
+	}
+
+	best := slices.MaxFunc(finals, func(a, b candidate) int {
+		return a.version.compare(b.version)
+	})
+
+	return best.text, true
+}
+
+func (s pep440Specifier) namesPrerelease() bool {
+	return slices.ContainsFunc(s, func(c clause) bool {
+		return c.op != "===" && c.version.prerelease()
+	})
+}
+
+func (s pep440Specifier) satisfies(text string, v pep440) bool {
+	for _, c := range s {
+		if !c.satisfies(text, v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c clause) satisfies(text string, v pep440) bool {
+	switch c.op {
+	case "===":
+		return text == c.text
+	case "==":
+		return c.equal(v)
+	case "!=":
+		return !c.equal(v)
+	case "~=":
+		// "~=1.4.5" means ">=1.4.5, ==1.4.*".
+		prefix := clause{version: pep440{epoch: c.version.epoch, release: c.version.release[:len(c.version.release)-1]}}
+		return v.compare(c.version) >= 0 && prefix.hasPrefix(v)
+	case "<=":
+		return v.compare(c.version) <= 0
+	case ">=":
+		return v.compare(c.version) >= 0
+	case "<":
+		return v.compare(c.version) < 0
+	case ">":
+		return v.compare(c.version) > 0
+	default:
+		return false
+	}
+}
+
+func (c clause) equal(v pep440) bool {
+	if c.prefix {
+		return c.hasPrefix(v)
+	}
+
+	return v.compare(c.version) == 0
+}
+
+// hasPrefix reports whether the release of v starts with the release segments of the clause, in the same epoch.
+func (c clause) hasPrefix(v pep440) bool {
+	if v.epoch != c.version.epoch {
+		return false
+	}
+
+	for i, n := range c.version.release {
+		segment := 0
+		if i < len(v.release) {
+			segment = v.release[i]
+		}
+
+		if segment != n {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,174 @@
+package dependency
+
+import "testing"
+
+func TestPEP440Compare(t *testing.T) {
+	// Every version is lower than the ones after it.
+	ordered := []string{
+		"0.9",
+		"1.0.dev0",
+		"1.0a1",
+		"1.0a2.dev1",
+		"1.0a2",
+		"1.0b1",
+		"1.0rc1",
+		"1.0",
+		"1.0.post1.dev1",
+		"1.0.post1",
+		"1.0.1",
+		"1.1",
+		"2.0",
+		"10.0",
+		"1!0.5",
+	}
+
+	for i := range ordered {
+		for j := range ordered {
+			a, err := parsePEP440(ordered[i])
+			if err != nil {
+				t.Fatalf("parsePEP440(%q) error = %v", ordered[i], err)
+			}
+
+			b, err := parsePEP440(ordered[j])
+			if err != nil {
+				t.Fatalf("parsePEP440(%q) error = %v", ordered[j], err)
+			}
+
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+
+			if got := a.compare(b); got != want {
+				t.Errorf("compare(%q, %q) = %v, want %v", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+}
+
+func TestPEP440Normalization(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{a: "1.0", b: "1.0.0"},
+		{a: "v1.0", b: "1.0"},
+		{a: "1.0+local.1", b: "1.0"},
+		{a: "1.0alpha1", b: "1.0a1"},
+		{a: "1.0-beta.2", b: "1.0b2"},
+		{a: "1.0c1", b: "1.0rc1"},
+		{a: "1.0-preview2", b: "1.0rc2"},
+		{a: "1.0-1", b: "1.0.post1"},
+		{a: "1.0.rev2", b: "1.0.post2"},
+		{a: "1.0-dev3", b: "1.0.dev3"},
+		{a: "1.0RC1", b: "1.0rc1"},
+	}
+
+	for _, tt := range tests {
+		a, err := parsePEP440(tt.a)
+		if err != nil {
+			t.Fatalf("parsePEP440(%q) error = %v", tt.a, err)
+		}
+
+		b, err := parsePEP440(tt.b)
+		if err != nil {
+			t.Fatalf("parsePEP440(%q) error = %v", tt.b, err)
+		}
+
+		if a.compare(b) != 0 {
+			t.Errorf("%q and %q are different versions", tt.a, tt.b)
+		}
+	}
+}
+
+func TestParsePEP440Invalid(t *testing.T) {
+	for _, version := range []string{"", "abc", "1.0-foo", "1..0", "1.0a1b1"} {
+		if _, err := parsePEP440(version); err == nil {
+			t.Errorf("parsePEP440(%q) succeeded, want an error", version)
+		}
+	}
+}
+
+func TestPEP440SpecifierMatch(t *testing.T) {
+	versions := []string{"1.0", "1.4", "1.4.5", "1.4.9", "1.5.0", "2.0a1", "2.0", "2.1.post1", "3.0rc1", "not a version"}
+
+	tests := []struct {
+		specifier string
+		want      string
+		wantOK    bool
+	}{
+		{specifier: "", want: "2.1.post1", wantOK: true},
+		{specifier: "==1.4", want: "1.4", wantOK: true},
+		{specifier: "== 1.0", want: "1.0", wantOK: true},
+		{specifier: "==1.4.*", want: "1.4.9", wantOK: true},
+		{specifier: "!=2.1.post1", want: "2.0", wantOK: true},
+		{specifier: "!=2.*", want: "1.5.0", wantOK: true},
+		{specifier: "===1.4.5", want: "1.4.5", wantOK: true},
+		{specifier: "~=1.4.5", want: "1.4.9", wantOK: true},
+		{specifier: "~=1.4", want: "1.5.0", wantOK: true},
+		{specifier: ">=1.4,<2", want: "1.5.0", wantOK: true},
+		{specifier: ">=1.4, <2, !=1.5.0", want: "1.4.9", wantOK: true},
+		{specifier: "<=1.4.5", want: "1.4.5", wantOK: true},
+		{specifier: "<1.4.5", want: "1.4", wantOK: true},
+		{specifier: ">2.0", want: "2.1.post1", wantOK: true},
+		// A clause naming a prerelease admits prereleases.
+		{specifier: ">=2.0a1,<2.0", want: "2.0a1", wantOK: true},
+		// Without a matching final release, prereleases are used.
+		{specifier: ">2.5", want: "3.0rc1", wantOK: true},
+		{specifier: ">5", wantOK: false},
+		{specifier: "==1.3", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.specifier, func(t *testing.T) {
+			spec, err := parsePEP440Specifier(tt.specifier)
+			if err != nil {
+				t.Fatalf("parsePEP440Specifier(%q) error = %v", tt.specifier, err)
+			}
+
+			got, ok := spec.Match(versions)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Match() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParsePEP440SpecifierInvalid(t *testing.T) {
+	for _, specifier := range []string{"1.0", "~=1", ">=abc", "=>1.0", ">=1.0,latest"} {
+		if _, err := parsePEP440Specifier(specifier); err == nil {
+			t.Errorf("parsePEP440Specifier(%q) succeeded, want an error", specifier)
+		}
+	}
+}
+
+func TestPoetrySpecifier(t *testing.T) {
+	tests := []struct {
+		constraint string
+		want       string
+	}{
+		{constraint: "^1.2.3", want: ">=1.2.3,<2"},
+		{constraint: "^0.2.3", want: ">=0.2.3,<0.3"},
+		{constraint: "^0.0.3", want: ">=0.0.3,<0.0.4"},
+		{constraint: "^2", want: ">=2,<3"},
+		{constraint: "~1.2.3", want: ">=1.2.3,<1.3"},
+		{constraint: "~1", want: ">=1,<2"},
+		{constraint: "~=1.2", want: "~=1.2"},
+		{constraint: "1.2.3", want: "==1.2.3"},
+		{constraint: "*", want: ""},
+		{constraint: ">=1.0, <2.0", want: ">=1.0,<2.0"},
+	}
+
+	for _, tt := range tests {
+		got, err := poetrySpecifier(tt.constraint)
+		if err != nil {
+			t.Fatalf("poetrySpecifier(%q) error = %v", tt.constraint, err)
+		}
+
+		if got != tt.want {
+			t.Errorf("poetrySpecifier(%q) = %q, want %q", tt.constraint, got, tt.want)
+		}
+	}
+}
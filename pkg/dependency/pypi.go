@@ -0,0 +1,628 @@
+package dependency
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+var (
+	// requirementPattern matches a PEP 508 requirement, e.g. `requests[socks] >= 2.0 ; python_version >= "3.8"`, as
+	// the name, the extras and the rest.
+	requirementPattern = regexp.MustCompile(`^([A-Za-z0-9](?:[A-Za-z0-9._-]*[A-Za-z0-9])?)\s*(\[[^\]]*\])?\s*(.*)$`)
+
+	anchorPattern = regexp.MustCompile(`(?is)<a\s([^>]*)>(.*?)</a>`)
+	hrefPattern   = regexp.MustCompile(`(?i)href\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	nameSeparator = regexp.MustCompile(`[-_.]+`)
+)
+
+// PyPI resolves the dependencies of Python projects, and fetches them from a package index implementing the simple
+// repository API (https://peps.python.org/pep-0503/), such as PyPI or a local mirror.
+type PyPI struct {
+	index  string
+	client *http.Client
+}
+
+// NewPyPI creates a PyPI resolver for the simple index at the given URL, e.g. https://pypi.org/simple.
+func NewPyPI(index string, client *http.Client) *PyPI {
+	return &PyPI{
+		index:  strings.TrimSuffix(index, "/"),
+		client: client,
+	}
+}
+
+// Languages is a method of the PyPI struct. It returns Python.
+func (p *PyPI) Languages() []string {
+	return []string{"Python"}
+}
+
+// Detect is a method of the PyPI struct. It reports whether dir contains Pipfile.lock, requirements*.txt, pyproject.toml or setup.cfg.
+func (p *PyPI) Detect(dir string) bool {
+	return exists(dir, "Pipfile.lock", "requirements*.txt", "pyproject.toml", "setup.cfg")
+}
+
+// requirement is a dependency of a Python project, with a PEP 440 version specifier. A requirement that cannot be
+// installed from an index, e.g. a git repository or a local path, has no name.
+type requirement struct {
+	name      string
+	specifier string
+	text      string
+}
+
+// Dependencies is a method of the PyPI struct. It returns the dependencies of the project in dir, resolved to the
+// highest version of the index satisfying the requirements, and the requirements it could not resolve. Pipfile.lock
+// pins every dependency and takes precedence. Otherwise, the requirements of requirements*.txt, pyproject.toml (PEP
+// 621 and Poetry) and setup.cfg are combined, which usually only covers the direct dependencies. Optional and
+// development dependencies are left out.
+func (p *PyPI) Dependencies(ctx context.Context, dir string) ([]Dependency, []string, error) {
+	requirements, err := pythonRequirements(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		deps       []Dependency
+		unresolved []string
+		errs       []error
+		seen       = make(map[string]bool)
+	)
+
+	for _, req := range requirements {
+		if req.name != "" && seen[req.name] {
+			continue
+		}
+
+		seen[req.name] = true
+
+		if req.name == "" {
+			unresolved = append(unresolved, req.text)
+			continue
+		}
+
+		dep, err := p.resolve(ctx, req)
+		if err != nil {
+			unresolved = append(unresolved, req.text)
+
+			// An index that cannot be reached is an error, a requirement it cannot satisfy is not.
+			if !errors.Is(err, errUnsatisfiable) {
+				errs = append(errs, err)
+			}
+
+			continue
+		}
+
+		deps = append(deps, dep)
+	}
+
+	return deps, unresolved, errors.Join(errs...)
+}
+
+// Fetch is a method of the PyPI struct. It downloads the wheel or source distribution of a resolved dependency,
+// verifies it against the hash published by the index, and extracts it into dir.
+func (p *PyPI) Fetch(ctx context.Context, dep Dependency, dir string) error {
+	extract := extractZip
+	if strings.HasSuffix(dep.URL, ".tar.gz") || strings.HasSuffix(dep.URL, ".tgz") {
+		extract = extractTarGz
+	}
+
+	body, err := get(ctx, p.client, dep.URL, "")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	if err = extractVerified(body, dep.Integrity, dir, extract); err != nil {
+		return fmt.Errorf("unable to extract %v: %v", dep, err)
+	}
+
+	return nil
+}
+
+var errUnsatisfiable = errors.New("no distribution satisfies the requirement")
+
+// distribution is a file of the index, a wheel or a source distribution of a version of a package.
+type distribution struct {
+	version   string
+	url       string
+	integrity string
+	yanked    bool
+
+	// rank orders the distributions of a version by preference: pure Python wheels contain exactly the installed
+	// code, source distributions come with tests and documentation, and the other wheels with compiled extensions.
+	rank int
+}
+
+// resolve is a method of the PyPI struct. It resolves a requirement to the preferred distribution of the highest
+// version of the index satisfying it. Yanked versions are only used if no other version does.
+func (p *PyPI) resolve(ctx context.Context, req requirement) (Dependency, error) {
+	spec, err := parsePEP440Specifier(req.specifier)
+	if err != nil {
+		return Dependency{}, fmt.Errorf("%w: %v", errUnsatisfiable, err)
+	}
+
+	distributions, err := p.distributions(ctx, req.name)
+	if errors.Is(err, errNotFound) {
+		return Dependency{}, fmt.Errorf("%w: %v", errUnsatisfiable, err)
+	}
+	if err != nil {
+		return Dependency{}, err
+	}
+
+	for _, yanked := range []bool{false, true} {
+		var versions []string
+
+		for _, d := range distributions {
+			if !d.yanked || yanked {
+				versions = append(versions, d.version)
+			}
+		}
+
+		version, ok := spec.Match(versions)
+		if !ok {
+			continue
+		}
+
+		best := distribution{rank: -1}
+
+		for _, d := range distributions {
+			if d.version == version && (!d.yanked || yanked) && d.rank > best.rank {
+				best = d
+			}
+		}
+
+		return Dependency{Name: req.name, Version: version, Integrity: best.integrity, URL: best.url}, nil
+	}
+
+	return Dependency{}, fmt.Errorf("%w: %v", errUnsatisfiable, req.text)
+}
+
+// distributions is a method of the PyPI struct. It lists the wheels and source distributions of a package on its page
+// of the simple index.
+func (p *PyPI) distributions(ctx context.Context, name string) ([]distribution, error) {
+	page, err := url.Parse(p.index + "/" + name + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := get(ctx, p.client, page.String(), "text/html")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = body.Close() }()
+
+	b, err := io.ReadAll(io.LimitReader(body, 64<<20))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the index page of %v: %v", name, err)
+	}
+
+	var distributions []distribution
+
+	for _, anchor := range anchorPattern.FindAllStringSubmatch(string(b), -1) {
+		href := hrefPattern.FindStringSubmatch(anchor[1])
+		if href == nil {
+			continue
+		}
+
+		link, err := page.Parse(html.UnescapeString(href[1] + href[2]))
+		if err != nil {
+			continue
+		}
+
+		d, ok := parseDistribution(name, html.UnescapeString(strings.TrimSpace(anchor[2])))
+		if !ok {
+			continue
+		}
+
+		if algorithm, digest, ok := strings.Cut(link.Fragment, "="); ok {
+			if sum, err := hex.DecodeString(digest); err == nil {
+				d.integrity = algorithm + "-" + base64.StdEncoding.EncodeToString(sum)
+			}
+		}
+
+		link.Fragment = ""
+		d.url = link.String()
+		d.yanked = strings.Contains(strings.ToLower(anchor[1]), "data-yanked")
+
+		distributions = append(distributions, d)
+	}
+
+	return distributions, nil
+}
+
+// parseDistribution reads the version and the kind of a distribution from its file name, e.g.
+// "requests-2.31.0-py3-none-any.whl" or "python-dateutil-2.8.2.tar.gz".
+func parseDistribution(name string, filename string) (distribution, bool) {
+	if base, ok := strings.CutSuffix(filename, ".whl"); ok {
+		parts := strings.Split(base, "-")
+		if len(parts) < 5 || normalizeName(parts[0]) != name {
+			return distribution{}, false
+		}
+
+		rank := 0
+		if strings.HasSuffix(base, "-none-any") {
+			rank = 2
+		}
+
+		return distribution{version: parts[1], rank: rank}, true
+	}
+
+	for _, suffix := range []string{".tar.gz", ".zip"} {
+		if base, ok := strings.CutSuffix(filename, suffix); ok {
+			i := strings.LastIndex(base, "-")
+			if i <= 0 || normalizeName(base[:i]) != name {
+				return distribution{}, false
+			}
+
+			return distribution{version: base[i+1:], rank: 1}, true
+		}
+	}
+
+	return distribution{}, false
+}
+
+// normalizeName normalizes the name of a Python package, see https://peps.python.org/pep-0503/#normalized-names.
+func normalizeName(name string) string {
+	return strings.ToLower(nameSeparator.ReplaceAllString(name, "-"))
+}
+
+// pythonRequirements reads the requirements of the Python project in dir from its manifests.
+func pythonRequirements(dir string) ([]requirement, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "Pipfile.lock"))
+	if err == nil {
+		requirements, err := parsePipfileLock(b)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse Pipfile.lock: %v", err)
+		}
+
+		return requirements, nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "requirements*.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	var requirements []requirement
+
+	for _, file := range files {
+		if b, err = os.ReadFile(file); err != nil {
+			return nil, err
+		}
+
+		requirements = append(requirements, parseRequirementsTxt(b)...)
+	}
+
+	parsers := []struct {
+		name  string
+		parse func([]byte) ([]requirement, error)
+	}{
+		{"pyproject.toml", parsePyproject},
+		{"setup.cfg", parseSetupCfg},
+	}
+
+	for _, p := range parsers {
+		b, err := os.ReadFile(filepath.Join(dir, p.name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := p.parse(b)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %v: %v", p.name, err)
+		}
+
+		requirements = append(requirements, r...)
+	}
+
+	return requirements, nil
+}
+
+// parseRequirement parses a PEP 508 requirement. It returns false for requirements that only apply to an extra, which
+// are optional.
+func parseRequirement(text string) (requirement, bool) {
+	text = strings.TrimSpace(text)
+
+	spec, markers, _ := strings.Cut(text, ";")
+	if strings.Contains(markers, "extra") {
+		return requirement{}, false
+	}
+
+	m := requirementPattern.FindStringSubmatch(strings.TrimSpace(spec))
+	if m == nil || strings.HasPrefix(m[3], "@") {
+		// A direct reference, e.g. "name @ git+https://...", is not installed from the index.
+		return requirement{text: text}, true
+	}
+
+	specifier := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(m[3]), "("), ")")
+
+	return requirement{name: normalizeName(m[1]), specifier: specifier, text: text}, true
+}
+
+// parseRequirementsTxt parses a pip requirements file. Options such as "-r" and "--index-url" are skipped, editable
+// installs and paths are returned as requirements without a name.
+func parseRequirementsTxt(b []byte) []requirement {
+	var (
+		requirements []requirement
+		line         string
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(string(b)))
+
+	for scanner.Scan() {
+		text := scanner.Text()
+
+		// A backslash at the end of a line continues it on the next.
+		if continued, ok := strings.CutSuffix(text, "\\"); ok {
+			line += continued + " "
+			continue
+		}
+
+		line += text
+		text, line = line, ""
+
+		if i := strings.Index(text, " #"); i >= 0 || strings.HasPrefix(text, "#") {
+			text = text[:max(i, 0)]
+		}
+
+		// Per-requirement options such as "--hash" follow the requirement.
+		if i := strings.Index(text, " --"); i >= 0 {
+			text = text[:i]
+		}
+
+		text = strings.TrimSpace(text)
+
+		switch {
+		case text == "":
+		case strings.HasPrefix(text, "-e") || strings.HasPrefix(text, "--editable"):
+			requirements = append(requirements, requirement{text: text})
+		case strings.HasPrefix(text, "-"):
+		case strings.Contains(text, "://") || strings.HasPrefix(text, ".") || strings.HasPrefix(text, "/"):
+			requirements = append(requirements, requirement{text: text})
+		default:
+			if r, ok := parseRequirement(text); ok {
+				requirements = append(requirements, r)
+			}
+		}
+	}
+
+	return requirements
+}
+
+// parsePyproject parses the dependencies of pyproject.toml, both the PEP 621 "project.dependencies" and the
+// "tool.poetry.dependencies" of Poetry.
+func parsePyproject(b []byte) ([]requirement, error) {
+	var pyproject struct {
+		Project struct {
+			Dependencies []string `toml:"dependencies"`
+		} `toml:"project"`
+		Tool struct {
+			Poetry struct {
+				Dependencies map[string]any `toml:"dependencies"`
+			} `toml:"poetry"`
+		} `toml:"tool"`
+	}
+
+	if err := toml.Unmarshal(b, &pyproject); err != nil {
+		return nil, err
+	}
+
+	var requirements []requirement
+
+	for _, text := range pyproject.Project.Dependencies {
+		if r, ok := parseRequirement(text); ok {
+			requirements = append(requirements, r)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(pyproject.Tool.Poetry.Dependencies)) {
+		if strings.EqualFold(name, "python") {
+			continue
+		}
+
+		if r, ok := poetryRequirement(name, pyproject.Tool.Poetry.Dependencies[name]); ok {
+			requirements = append(requirements, r)
+		}
+	}
+
+	return requirements, nil
+}
+
+// poetryRequirement translates a Poetry dependency, either a version constraint or a table, into a requirement. It
+// returns false for optional dependencies.
+func poetryRequirement(name string, value any) (requirement, bool) {
+	text := name
+	if constraint, ok := value.(string); ok {
+		text += " " + constraint
+	}
+
+	var constraint string
+
+	switch v := value.(type) {
+	case string:
+		constraint = v
+	case map[string]any:
+		if optional, _ := v["optional"].(bool); optional {
+			return requirement{}, false
+		}
+
+		version, ok := v["version"].(string)
+		if !ok {
+			// A git repository, a path or a URL.
+			return requirement{text: text}, true
+		}
+
+		constraint = version
+	case []any:
+		// Multiple constraints apply to different environments, the first one is used.
+		if len(v) == 0 {
+			return requirement{}, false
+		}
+
+		return poetryRequirement(name, v[0])
+	default:
+		return requirement{text: text}, true
+	}
+
+	specifier, err := poetrySpecifier(constraint)
+	if err != nil {
+		return requirement{text: text}, true
+	}
+
+	return requirement{name: normalizeName(name), specifier: specifier, text: text}, true
+}
+
+// poetrySpecifier translates a Poetry version constraint, which adds caret, tilde and bare versions to PEP 440, into
+// a PEP 440 specifier.
+func poetrySpecifier(constraint string) (string, error) {
+	var clauses []string
+
+	for _, part := range strings.Split(constraint, ",") {
+		part = strings.TrimSpace(part)
+
+		switch {
+		case part == "" || part == "*":
+		case strings.HasPrefix(part, "^"), strings.HasPrefix(part, "~") && !strings.HasPrefix(part, "~="):
+			op, version := part[:1], strings.TrimSpace(part[1:])
+
+			v, err := parsePEP440(version)
+			if err != nil {
+				return "", err
+			}
+
+			clauses = append(clauses, ">="+version, "<"+poetryUpperBound(op, v.release))
+		case strings.ContainsAny(part[:1], "<>=!~"):
+			clauses = append(clauses, part)
+		default:
+			clauses = append(clauses, "=="+part)
+		}
+	}
+
+	return strings.Join(clauses, ","), nil
+}
+
+// poetryUpperBound returns the exclusive upper bound of a caret or tilde constraint on the release. A caret allows
+// every update that does not change the leftmost non-zero segment, a tilde only patch updates, or minor updates if
+// only the major version is given.
+func poetryUpperBound(op string, release []int) string {
+	i := 0
+
+	switch op {
+	case "^":
+		for i < len(release)-1 && release[i] == 0 {
+			i++
+		}
+	case "~":
+		if len(release) > 1 {
+			i = 1
+		}
+	}
+
+	bound := make([]string, i+1)
+	for j := range i {
+		bound[j] = fmt.Sprint(release[j])
+	}
+
+	bound[i] = fmt.Sprint(release[i] + 1)
+
+	return strings.Join(bound, ".")
+}
+
+// parsePipfileLock parses the default, i.e. non-development, packages of Pipfile.lock.
+func parsePipfileLock(b []byte) ([]requirement, error) {
+	var lock struct {
+		Default map[string]struct {
+			Version string `json:"version"`
+		} `json:"default"`
+	}
+
+	if err := json.Unmarshal(b, &lock); err != nil {
+		return nil, err
+	}
+
+	var requirements []requirement
+
+	for _, name := range slices.Sorted(maps.Keys(lock.Default)) {
+		version := lock.Default[name].Version
+		if version == "" {
+			requirements = append(requirements, requirement{text: name})
+			continue
+		}
+
+		requirements = append(requirements, requirement{name: normalizeName(name), specifier: version, text: name + version})
+	}
+
+	return requirements, nil
+}
+
+// parseSetupCfg parses the "install_requires" option of the "options" section of setup.cfg, which lists one
+// requirement per line, possibly starting on the line of the option itself.
+func parseSetupCfg(b []byte) ([]requirement, error) {
+	var (
+		requirements []requirement
+		section      string
+		inRequires   bool
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(string(b)))
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+			continue
+		}
+
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+
+		if !indented {
+			inRequires = false
+
+			if strings.HasPrefix(trimmed, "[") {
+				section = strings.Trim(trimmed, "[]")
+				continue
+			}
+
+			key, value, ok := strings.Cut(trimmed, "=")
+			if !ok || section != "options" || strings.TrimSpace(key) != "install_requires" {
+				continue
+			}
+
+			inRequires, trimmed = true, strings.TrimSpace(value)
+			if trimmed == "" {
+				continue
+			}
+		}
+
+		if inRequires {
+			if r, ok := parseRequirement(trimmed); ok {
+				requirements = append(requirements, r)
+			}
+		}
+	}
+
+	return requirements, scanner.Err()
+}
@@ -0,0 +1,380 @@
+package dependency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRequirementsTxt(t *testing.T) {
+	txt := `# comment
+requests>=2.30,<2.32  # inline comment
+python-dateutil==2.8.2 \
+    --hash=sha256:abc
+Django_REST.framework (>=3.14)
+numpy
+-r other.txt
+--index-url https://example.com/simple
+-e git+https://github.com/user/lib.git#egg=lib
+./local-package
+https://example.com/pkg.tar.gz
+name @ git+https://github.com/user/name.git
+pytest ; extra == "test"
+uvloop ; sys_platform != "win32"
+`
+
+	want := []requirement{
+		{name: "requests", specifier: ">=2.30,<2.32", text: "requests>=2.30,<2.32"},
+		{name: "python-dateutil", specifier: "==2.8.2", text: "python-dateutil==2.8.2"},
+		{name: "django-rest-framework", specifier: ">=3.14", text: "Django_REST.framework (>=3.14)"},
+		{name: "numpy", text: "numpy"},
+		{text: "-e git+https://github.com/user/lib.git#egg=lib"},
+		{text: "./local-package"},
+		{text: "https://example.com/pkg.tar.gz"},
+		{text: "name @ git+https://github.com/user/name.git"},
+		{name: "uvloop", text: `uvloop ; sys_platform != "win32"`},
+	}
+
+	if got := parseRequirementsTxt([]byte(txt)); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRequirementsTxt() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParsePyproject(t *testing.T) {
+	pyproject := `
+[project]
+name = "app"
+dependencies = [
+    "requests>=2.30",
+    "rich[jupyter]",
+    "pytest; extra == 'test'",
+]
+
+[project.optional-dependencies]
+docs = ["sphinx"]
+
+[tool.poetry.dependencies]
+python = "^3.8"
+numpy = [
+    { version = "^1.26", python = ">=3.9" },
+    { version = "^1.24", python = "<3.9" },
+]
+flask = "~2.3"
+black = { version = "^23.0", optional = true }
+mylib = { git = "https://github.com/user/mylib.git" }
+pendulum = { version = "3.0.0", extras = ["test"] }
+
+[tool.poetry.group.dev.dependencies]
+mypy = "^1.0"
+`
+
+	want := []requirement{
+		{name: "requests", specifier: ">=2.30", text: "requests>=2.30"},
+		{name: "rich", text: "rich[jupyter]"},
+		{name: "flask", specifier: ">=2.3,<2.4", text: "flask ~2.3"},
+		{text: "mylib"},
+		{name: "numpy", specifier: ">=1.26,<2", text: "numpy"},
+		{name: "pendulum", specifier: "==3.0.0", text: "pendulum"},
+	}
+
+	got, err := parsePyproject([]byte(pyproject))
+	if err != nil {
+		t.Fatalf("parsePyproject() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePyproject() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseSetupCfg(t *testing.T) {
+	cfg := `[metadata]
+name = app
+install_requires = ignored
+
+[options]
+packages = find:
+install_requires = click>=8
+    requests>=2.0
+    # comment
+    importlib-metadata; python_version < "3.8"
+python_requires = >=3.7
+
+[options.extras_require]
+test = pytest
+`
+
+	want := []requirement{
+		{name: "click", specifier: ">=8", text: "click>=8"},
+		{name: "requests", specifier: ">=2.0", text: "requests>=2.0"},
+		{name: "importlib-metadata", text: `importlib-metadata; python_version < "3.8"`},
+	}
+
+	got, err := parseSetupCfg([]byte(cfg))
+	if err != nil {
+		t.Fatalf("parseSetupCfg() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSetupCfg() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParsePipfileLock(t *testing.T) {
+	lock := `{
+		"_meta": {"hash": {"sha256": "abc"}},
+		"default": {
+			"requests": {"hashes": ["sha256:abc"], "version": "==2.31.0"},
+			"Django": {"version": "==4.2.0"},
+			"mylib": {"git": "https://github.com/user/mylib.git", "ref": "abc"}
+		},
+		"develop": {
+			"pytest": {"version": "==7.4.0"}
+		}
+	}`
+
+	want := []requirement{
+		{name: "django", specifier: "==4.2.0", text: "Django==4.2.0"},
+		{text: "mylib"},
+		{name: "requests", specifier: "==2.31.0", text: "requests==2.31.0"},
+	}
+
+	got, err := parsePipfileLock([]byte(lock))
+	if err != nil {
+		t.Fatalf("parsePipfileLock() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePipfileLock() = %+v, want %+v", got, want)
+	}
+}
+
+// pypiIndex serves a simple index like PyPI or a local mirror, with a page per package listing the given files, which
+// are served below /packages/. The page of a package named "broken" fails.
+func pypiIndex(t *testing.T, packages map[string][]string, files map[string][]byte, yanked ...string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/simple/broken/" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if name, ok := strings.CutPrefix(r.URL.Path, "/simple/"); ok {
+			filenames, ok := packages[strings.TrimSuffix(name, "/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+
+			var page strings.Builder
+
+			page.WriteString("<!DOCTYPE html><html><body>\n")
+
+			for _, filename := range filenames {
+				href := "../../packages/" + filename
+				if b, ok := files[filename]; ok {
+					sum := sha256.Sum256(b)
+					href += "#sha256=" + hex.EncodeToString(sum[:])
+				}
+
+				attributes := fmt.Sprintf("href=%q", href)
+				for _, y := range yanked {
+					if y == filename {
+						attributes += ` data-yanked=""`
+					}
+				}
+
+				_, _ = fmt.Fprintf(&page, "<a %s>%s</a><br/>\n", attributes, filename)
+			}
+
+			page.WriteString("</body></html>\n")
+
+			_, _ = w.Write([]byte(page.String()))
+			return
+		}
+
+		if b, ok := files[strings.TrimPrefix(r.URL.Path, "/packages/")]; ok {
+			_, _ = w.Write(b)
+			return
+		}
+
+		http.NotFound(w, r)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestPyPIDependencies(t *testing.T) {
+	files := map[string][]byte{
+		"requests-2.30.0-py3-none-any.whl":              []byte("requests 2.30.0 wheel"),
+		"requests-2.31.0.tar.gz":                        []byte("requests 2.31.0 sdist"),
+		"requests-2.31.0-py3-none-any.whl":              []byte("requests 2.31.0 wheel"),
+		"requests-2.32.0-py3-none-any.whl":              []byte("requests 2.32.0 wheel"),
+		"python_dateutil-2.8.2-py2.py3-none-any.whl":    []byte("dateutil wheel"),
+		"numpy-1.26.0-cp311-cp311-manylinux_x86_64.whl": []byte("numpy binary wheel"),
+		"numpy-1.26.0.tar.gz":                           []byte("numpy sdist"),
+	}
+
+	srv := pypiIndex(t, map[string][]string{
+		"requests": {
+			"requests-2.30.0-py3-none-any.whl",
+			"requests-2.31.0.tar.gz",
+			"requests-2.31.0-py3-none-any.whl",
+			"requests-2.32.0-py3-none-any.whl",
+		},
+		"python-dateutil": {"python_dateutil-2.8.2-py2.py3-none-any.whl"},
+		"numpy":           {"numpy-1.26.0-cp311-cp311-manylinux_x86_64.whl", "numpy-1.26.0.tar.gz"},
+	}, files, "requests-2.32.0-py3-none-any.whl")
+
+	p := NewPyPI(srv.URL+"/simple/", srv.Client())
+
+	dep := func(name, version, filename string) Dependency {
+		return Dependency{
+			Name:      name,
+			Version:   version,
+			Integrity: sha256SRI(files[filename]),
+			URL:       srv.URL + "/packages/" + filename,
+		}
+	}
+
+	tests := []struct {
+		name           string
+		files          map[string]string
+		want           []Dependency
+		wantUnresolved []string
+		wantErr        bool
+	}{
+		{
+			name: "requirements",
+			files: map[string]string{
+				"requirements.txt":      "requests>=2.30\n-e git+https://github.com/user/lib.git#egg=lib\nmissing-package>=1.0\n",
+				"requirements-prod.txt": "Python_Dateutil==2.8.2\nrequests\n",
+				"pyproject.toml":        "[project]\ndependencies = [\"numpy>=1.20\", \"requests>=3\"]\n",
+			},
+			// The yanked 2.32.0 is only used if no other version satisfies a requirement. Pure Python wheels are
+			// preferred over source distributions, and those over binary wheels.
+			want: []Dependency{
+				dep("python-dateutil", "2.8.2", "python_dateutil-2.8.2-py2.py3-none-any.whl"),
+				dep("requests", "2.31.0", "requests-2.31.0-py3-none-any.whl"),
+				dep("numpy", "1.26.0", "numpy-1.26.0.tar.gz"),
+			},
+			wantUnresolved: []string{"-e git+https://github.com/user/lib.git#egg=lib", "missing-package>=1.0"},
+		},
+		{
+			name: "yanked",
+			files: map[string]string{
+				"requirements.txt": "requests==2.32.0\nrequests>=2.33\n",
+			},
+			want: []Dependency{dep("requests", "2.32.0", "requests-2.32.0-py3-none-any.whl")},
+		},
+		{
+			name: "unsatisfiable",
+			files: map[string]string{
+				"setup.cfg": "[options]\ninstall_requires =\n    requests>=3\n    numpy==1.26.0\n",
+			},
+			want:           []Dependency{dep("numpy", "1.26.0", "numpy-1.26.0.tar.gz")},
+			wantUnresolved: []string{"requests>=3"},
+		},
+		{
+			name: "Pipfile.lock takes precedence",
+			files: map[string]string{
+				"Pipfile.lock":     `{"default": {"requests": {"version": "==2.30.0"}, "mylib": {"git": "https://github.com/user/mylib.git"}}}`,
+				"requirements.txt": "numpy\n",
+			},
+			want:           []Dependency{dep("requests", "2.30.0", "requests-2.30.0-py3-none-any.whl")},
+			wantUnresolved: []string{"mylib"},
+		},
+		{
+			name:           "unreachable index",
+			files:          map[string]string{"requirements.txt": "broken\n"},
+			wantUnresolved: []string{"broken"},
+			wantErr:        true,
+		},
+		{
+			name:    "malformed pyproject.toml",
+			files:   map[string]string{"pyproject.toml": "[project\n"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unresolved, err := p.Dependencies(context.Background(), writeFiles(t, tt.files))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Dependencies() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Dependencies() = %v, want %v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(unresolved, tt.wantUnresolved) {
+				t.Errorf("Dependencies() unresolved = %v, want %v", unresolved, tt.wantUnresolved)
+			}
+		})
+	}
+}
+
+func TestPyPIFetch(t *testing.T) {
+	wheel := zipArchive(t, map[string]string{"requests/__init__.py": "__version__ = '2.31.0'\n"})
+	sdist := tarGz(t, map[string]string{"requests-2.31.0/requests/__init__.py": "__version__ = '2.31.0'\n"})
+
+	srv := pypiIndex(t, nil, map[string][]byte{
+		"requests-2.31.0-py3-none-any.whl": wheel,
+		"requests-2.31.0.tar.gz":           sdist,
+	})
+
+	p := NewPyPI(srv.URL+"/simple", srv.Client())
+
+	tests := []struct {
+		name     string
+		dep      Dependency
+		wantFile string
+		wantErr  bool
+	}{
+		{
+			name:     "wheel",
+			dep:      Dependency{Name: "requests", Version: "2.31.0", Integrity: sha256SRI(wheel), URL: srv.URL + "/packages/requests-2.31.0-py3-none-any.whl"},
+			wantFile: "requests/__init__.py",
+		},
+		{
+			name:     "source distribution",
+			dep:      Dependency{Name: "requests", Version: "2.31.0", Integrity: sha256SRI(sdist), URL: srv.URL + "/packages/requests-2.31.0.tar.gz"},
+			wantFile: "requests-2.31.0/requests/__init__.py",
+		},
+		{
+			name:    "hash mismatch",
+			dep:     Dependency{Name: "requests", Version: "2.31.0", Integrity: sha256SRI(sdist), URL: srv.URL + "/packages/requests-2.31.0-py3-none-any.whl"},
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			dep:     Dependency{Name: "requests", Version: "2.30.0", URL: srv.URL + "/packages/requests-2.30.0.tar.gz"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			err := p.Fetch(context.Background(), tt.dep, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if tt.wantFile != "" {
+				readFile(t, dir, tt.wantFile)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package dependency
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// DependencyResolver lists the dependencies of the projects of a package ecosystem and materialises their sources, so
+// the lines of code of the dependencies can be counted.
+type DependencyResolver interface {
+	// Languages returns the languages the code of the dependencies is counted in.
+	Languages() []string
+
+	// Detect reports whether the project in dir has a manifest of the ecosystem.
+	Detect(dir string) bool
+
+	// Dependencies returns the dependencies of the project in dir, pinned to a version, and the dependencies it could
+	// not resolve to a version that can be fetched.
+	Dependencies(ctx context.Context, dir string) ([]Dependency, []string, error)
+
+	// Fetch extracts the sources of a dependency into the existing directory dir.
+	Fetch(ctx context.Context, dep Dependency, dir string) error
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string][]DependencyResolver)
+)
+
+// Register adds a resolver for the projects of a language, as reported by GitHub, e.g. "TypeScript". A language may
+// have several resolvers, which are tried in the order they were registered. Resolvers registered before the server
+// starts take precedence over the built-in ones for the same language.
+func Register(language string, resolver DependencyResolver) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	key := strings.ToLower(language)
+	registry[key] = append(registry[key], resolver)
+}
+
+// Lookup returns the first resolver registered for the language that detects a manifest in dir, or nil if there is
+// none.
+func Lookup(language string, dir string) DependencyResolver {
+	registryMu.RLock()
+	resolvers := registry[strings.ToLower(language)]
+	registryMu.RUnlock()
+
+	for _, resolver := range resolvers {
+		if resolver.Detect(dir) {
+			return resolver
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+// Package server runs the REST API. Its main use is a main package of another module that registers its own dependency
+// resolvers with the dependency package before calling Run.
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"os"
+
+	"github.com/haapjari/repository-search-api/internal/pkg/cfg"
+	"github.com/haapjari/repository-search-api/internal/pkg/handler"
+)
+
+const (
+	host = "0.0.0.0"
+)
+
+// Run reads the configuration from the environment and serves the REST API until the server fails.
+func Run() error {
+	conf := cfg.NewConfig()
+
+	h, err := handler.NewHandler(conf)
+	if err != nil {
+		return fmt.Errorf("unable to create the handler: %v", err)
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/v1/repos/search", h.RepositoryHandler)
+	mux.HandleFunc("/api/v1/jobs", h.JobsHandler)
+	mux.HandleFunc("/api/v1/jobs/{id}", h.JobHandler)
+	mux.HandleFunc("/api/v1/jobs/{id}/results", h.JobResultsHandler)
+	mux.HandleFunc("/api/v1/jobs/{id}/events", h.JobEventsHandler)
+	mux.HandleFunc("/health", h.HealthCheckHandler)
+
+	if conf.EnableAdmin {
+		mux.HandleFunc("/api/v1/admin/tokens", h.TokensHandler)
+		mux.HandleFunc("/api/v1/admin/cache", h.CacheHandler)
+	}
+
+	if conf.EnablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		mux.HandleFunc("/debug/pprof/heap", pprof.Handler("heap").ServeHTTP)
+		mux.HandleFunc("/debug/pprof/goroutine", pprof.Handler("goroutine").ServeHTTP)
+		mux.HandleFunc("/debug/pprof/threadcreate", pprof.Handler("threadcreate").ServeHTTP)
+		mux.HandleFunc("/debug/pprof/block", pprof.Handler("block").ServeHTTP)
+		mux.HandleFunc("/debug/pprof/mutex", pprof.Handler("mutex").ServeHTTP)
+		mux.HandleFunc("/debug/pprof/allocs", pprof.Handler("allocs").ServeHTTP)
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	slog.Info("REST API | " + host + ":" + conf.Port)
+
+	if err = http.ListenAndServe(host+":"+conf.Port, mux); err != nil {
+		return fmt.Errorf("unable to start the server: %v", err)
+	}
+
+	return nil
+}